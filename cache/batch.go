@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Batcher is implemented by backends that can read, write or delete many keys
+// in a single round trip. GetMany returns hits only; missing keys are absent
+// from the map.
+type Batcher interface {
+	GetMany(ctx context.Context, keys []string) (map[string][]byte, error)
+	SetMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error
+	DelMany(ctx context.Context, keys []string) error
+}
+
+// GetMany returns the decoded hits for keys and the keys that were missing, in
+// the order they were requested. Backends that do not implement Batcher are
+// read one key at a time.
+func GetMany[T any](ctx context.Context, c Cache, keys []string, opts ...CallOption) (map[string]T, []string, error) {
+	cfg := newCallConfig(opts)
+	codec := codecFor(c, cfg)
+
+	data, err := getMany(ctx, c, keys)
+	if err != nil {
+		if len(keys) > 0 {
+			recordRead(ctx, c, keys[0], "get_many", cfg, 0, err)
+		}
+		return nil, nil, err
+	}
+
+	hits := make(map[string]T, len(data))
+	var misses []string
+	for _, key := range keys {
+		raw, ok := data[key]
+		if !ok {
+			recordRead(ctx, c, key, "get_many", cfg, 0, ErrNotFound)
+			misses = append(misses, key)
+			continue
+		}
+
+		result, err := decode[T](raw, codec)
+		recordRead(ctx, c, key, "get_many", cfg, len(raw), err)
+		if err != nil {
+			return nil, nil, err
+		}
+		hits[key] = result
+	}
+
+	return hits, misses, nil
+}
+
+// SetMany stores every item with the same ttl.
+func SetMany[T any](ctx context.Context, c Cache, items map[string]T, ttl time.Duration, opts ...CallOption) error {
+	cfg := newCallConfig(opts)
+	codec := codecFor(c, cfg)
+
+	data := make(map[string][]byte, len(items))
+	for key, value := range items {
+		raw, err := codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data[key] = raw
+	}
+
+	if len(cfg.tags) > 0 {
+		// Tagged entries are written one at a time, as Batcher has no tags.
+		for key, raw := range data {
+			err := set(ctx, c, key, raw, ttl, cfg)
+			recordWrite(ctx, c, key, "set_many", cfg, len(raw), err)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := setMany(ctx, c, data, ttl)
+	for key, raw := range data {
+		recordWrite(ctx, c, key, "set_many", cfg, len(raw), err)
+		if err != nil {
+			// A failed batch is counted as one error.
+			break
+		}
+	}
+
+	return err
+}
+
+func DelMany(ctx context.Context, c Cache, keys []string) error {
+	if b, ok := c.(Batcher); ok {
+		return b.DelMany(ctx, keys)
+	}
+
+	for _, key := range keys {
+		if err := c.Del(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getMany(ctx context.Context, c Cache, keys []string) (map[string][]byte, error) {
+	if b, ok := c.(Batcher); ok {
+		return b.GetMany(ctx, keys)
+	}
+
+	data := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		raw, err := c.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data[key] = raw
+	}
+
+	return data, nil
+}
+
+func setMany(ctx context.Context, c Cache, data map[string][]byte, ttl time.Duration) error {
+	if b, ok := c.(Batcher); ok {
+		return b.SetMany(ctx, data, ttl)
+	}
+
+	for key, raw := range data {
+		if err := c.Set(ctx, key, raw, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
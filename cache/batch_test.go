@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+
+	caches := map[string]Cache{
+		"Batcher":  NewMemory(WithCleanupInterval(0)),
+		"Fallback": &mockCache{data: make(map[string][]byte)},
+	}
+
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			items := map[string]codecUser{
+				"user:1": {Name: "John"},
+				"user:2": {Name: "Jane"},
+			}
+			if err := SetMany(ctx, c, items, time.Minute); err != nil {
+				t.Fatalf("SetMany failed: %v", err)
+			}
+
+			hits, misses, err := GetMany[codecUser](ctx, c, []string{"user:1", "user:3", "user:2", "user:4"})
+			if err != nil {
+				t.Fatalf("GetMany failed: %v", err)
+			}
+			if len(hits) != 2 || hits["user:1"].Name != "John" || hits["user:2"].Name != "Jane" {
+				t.Errorf("unexpected hits %+v", hits)
+			}
+			if !slices.Equal(misses, []string{"user:3", "user:4"}) {
+				t.Errorf("expected misses in request order, got %v", misses)
+			}
+
+			if err := DelMany(ctx, c, []string{"user:1", "user:2"}); err != nil {
+				t.Fatalf("DelMany failed: %v", err)
+			}
+			hits, misses, _ = GetMany[codecUser](ctx, c, []string{"user:1", "user:2"})
+			if len(hits) != 0 || len(misses) != 2 {
+				t.Errorf("expected all keys deleted, got hits %v misses %v", hits, misses)
+			}
+		})
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	ctx := context.Background()
+	wantErr := errors.New("backend error")
+	m := &mockCache{data: make(map[string][]byte), err: wantErr}
+
+	if _, _, err := GetMany[string](ctx, m, []string{"key"}); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if err := SetMany(ctx, m, map[string]string{"key": "value"}, time.Minute); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if err := DelMany(ctx, m, []string{"key"}); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+
+	// Marshal error
+	m.err = nil
+	if err := SetMany(ctx, m, map[string]chan int{"key": make(chan int)}, time.Minute); err == nil {
+		t.Error("expected marshal error")
+	}
+
+	// Unmarshal error
+	m.data["bad"] = []byte("{invalid")
+	if _, _, err := GetMany[codecUser](ctx, m, []string{"bad"}); err == nil {
+		t.Error("expected unmarshal error")
+	}
+}
@@ -0,0 +1,400 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrUnavailable wraps the errors returned by a BreakerCache when the
+	// backend failed or was bypassed. Remember treats it as a miss on reads
+	// and ignores it on writes, so callers still get the result of fn.
+	ErrUnavailable = errors.New("cache: backend unavailable")
+	// ErrCircuitOpen is returned by a BreakerCache while its circuit is open.
+	ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", ErrUnavailable)
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // calls reach the backend
+	BreakerHalfOpen                     // one trial call reaches the backend
+	BreakerOpen                         // calls fail with ErrCircuitOpen without reaching the backend
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerCache guards a Cache with a circuit breaker, so a backend that is
+// down costs one fast error instead of a network timeout per call. After
+// FailureThreshold consecutive failures the circuit opens and every call
+// fails with ErrCircuitOpen; after OpenTimeout a single trial call is let
+// through, which closes the circuit again if it succeeds.
+//
+// Misses and cancelled contexts are not failures. Failures are returned
+// wrapped in ErrUnavailable, which makes Remember fail open: it calls fn when
+// the cache cannot be read and returns its result when it cannot be written.
+//
+// The state is exported as the cache.breaker.state gauge (0 closed,
+// 1 half-open, 2 open) and every change is counted in
+// cache.breaker.transitions.
+type BreakerCache struct {
+	cache       Cache
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+
+	registration metric.Registration
+}
+
+func NewBreaker(c Cache, opts ...Option) *BreakerCache {
+	cfg := &Config{
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	b := &BreakerCache{
+		cache:       c,
+		threshold:   max(1, cfg.FailureThreshold),
+		openTimeout: cfg.OpenTimeout,
+		now:         time.Now,
+	}
+
+	m := instruments()
+	attrs := metric.WithAttributes(attribute.String("cache.backend", backendName(c)))
+	b.registration, _ = m.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(m.breakerState, int64(b.State()), attrs)
+		return nil
+	}, m.breakerState)
+
+	return b
+}
+
+// State returns the current state of the circuit.
+func (b *BreakerCache) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// Unwrap returns the underlying cache.
+func (b *BreakerCache) Unwrap() Cache {
+	return b.cache
+}
+
+// Codec returns the codec of the underlying cache.
+func (b *BreakerCache) Codec() Codec {
+	if cc, ok := b.cache.(interface{ Codec() Codec }); ok {
+		return cc.Codec()
+	}
+
+	return nil
+}
+
+func (b *BreakerCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.do(ctx, func() error {
+		return b.cache.Set(ctx, key, value, ttl)
+	})
+}
+
+func (b *BreakerCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var val []byte
+	err := b.do(ctx, func() error {
+		var err error
+		val, err = b.cache.Get(ctx, key)
+		return err
+	})
+
+	return val, err
+}
+
+func (b *BreakerCache) Del(ctx context.Context, key string) error {
+	return b.do(ctx, func() error {
+		return b.cache.Del(ctx, key)
+	})
+}
+
+// Add stores value only when the key does not exist yet. It returns
+// errors.ErrUnsupported when the underlying cache is not an Adder.
+func (b *BreakerCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	a, ok := b.cache.(Adder)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+
+	var added bool
+	err := b.do(ctx, func() error {
+		var err error
+		added, err = a.Add(ctx, key, value, ttl)
+		return err
+	})
+
+	return added, err
+}
+
+func (b *BreakerCache) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	var data map[string][]byte
+	err := b.do(ctx, func() error {
+		var err error
+		data, err = getMany(ctx, b.cache, keys)
+		return err
+	})
+
+	return data, err
+}
+
+func (b *BreakerCache) SetMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	return b.do(ctx, func() error {
+		return setMany(ctx, b.cache, items, ttl)
+	})
+}
+
+func (b *BreakerCache) DelMany(ctx context.Context, keys []string) error {
+	return b.do(ctx, func() error {
+		return DelMany(ctx, b.cache, keys)
+	})
+}
+
+// Incr adds delta to the counter at key. It returns errors.ErrUnsupported
+// when the underlying cache is not a Counter.
+func (b *BreakerCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return b.count(ctx, func(c Counter) (int64, error) {
+		return c.Incr(ctx, key, delta, ttl)
+	})
+}
+
+// Decr subtracts delta from the counter at key. It returns
+// errors.ErrUnsupported when the underlying cache is not a Counter.
+func (b *BreakerCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return b.count(ctx, func(c Counter) (int64, error) {
+		return c.Decr(ctx, key, delta, ttl)
+	})
+}
+
+func (b *BreakerCache) count(ctx context.Context, fn func(c Counter) (int64, error)) (int64, error) {
+	c, ok := b.cache.(Counter)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+
+	var n int64
+	err := b.do(ctx, func() error {
+		var err error
+		n, err = fn(c)
+		return err
+	})
+
+	return n, err
+}
+
+// CompareAndExpire resets the TTL of key if it holds value. It returns
+// errors.ErrUnsupported when the underlying cache is not a Locker.
+func (b *BreakerCache) CompareAndExpire(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return b.compare(ctx, func(l Locker) (bool, error) {
+		return l.CompareAndExpire(ctx, key, value, ttl)
+	})
+}
+
+// CompareAndDelete deletes key if it holds value. It returns
+// errors.ErrUnsupported when the underlying cache is not a Locker.
+func (b *BreakerCache) CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error) {
+	return b.compare(ctx, func(l Locker) (bool, error) {
+		return l.CompareAndDelete(ctx, key, value)
+	})
+}
+
+func (b *BreakerCache) compare(ctx context.Context, fn func(l Locker) (bool, error)) (bool, error) {
+	l, ok := b.cache.(Locker)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+
+	var done bool
+	err := b.do(ctx, func() error {
+		var err error
+		done, err = fn(l)
+		return err
+	})
+
+	return done, err
+}
+
+func (b *BreakerCache) runScript(ctx context.Context, s *script, keys []string, args ...int64) ([]int64, error) {
+	var res []int64
+	err := b.do(ctx, func() error {
+		var err error
+		res, err = runScript(ctx, b.cache, s, keys, args...)
+		return err
+	})
+
+	return res, err
+}
+
+// Scan lists the keys of the underlying cache matching pattern. A scan that
+// fails midway counts as one failure.
+func (b *BreakerCache) Scan(ctx context.Context, pattern string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if !b.allow(ctx) {
+			yield("", ErrCircuitOpen)
+			return
+		}
+
+		var err error
+		for key, scanErr := range Scan(ctx, b.cache, pattern) {
+			if scanErr != nil {
+				err = scanErr
+				break
+			}
+			if !yield(key, nil) {
+				_ = b.done(ctx, nil)
+				return
+			}
+		}
+
+		if err := b.done(ctx, err); err != nil {
+			yield("", err)
+		}
+	}
+}
+
+func (b *BreakerCache) DelPattern(ctx context.Context, pattern string) (int64, error) {
+	var deleted int64
+	err := b.do(ctx, func() error {
+		var err error
+		deleted, err = DelPattern(ctx, b.cache, pattern)
+		return err
+	})
+
+	return deleted, err
+}
+
+// Ping checks the underlying cache, whatever the state of the circuit, so
+// readiness checks report the backend itself.
+func (b *BreakerCache) Ping(ctx context.Context) error {
+	return Ping(ctx, b.cache)
+}
+
+// Close stops exporting the breaker state. The underlying cache is left open.
+func (b *BreakerCache) Close() error {
+	if b.registration == nil {
+		return nil
+	}
+
+	return b.registration.Unregister()
+}
+
+// do runs fn unless the circuit is open and records its outcome.
+func (b *BreakerCache) do(ctx context.Context, fn func() error) error {
+	if !b.allow(ctx) {
+		return ErrCircuitOpen
+	}
+
+	return b.done(ctx, fn())
+}
+
+// done records the outcome of a call that was allowed through.
+func (b *BreakerCache) done(ctx context.Context, err error) error {
+	switch {
+	case err == nil, errors.Is(err, ErrNotFound), errors.Is(err, errors.ErrUnsupported):
+		b.success(ctx)
+		return err
+	case errors.Is(ctx.Err(), context.Canceled):
+		// The caller gave up, which says nothing about the backend.
+		b.mu.Lock()
+		b.trial = false
+		b.mu.Unlock()
+		return err
+	}
+
+	b.failure(ctx)
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
+// allow reports whether a call may reach the backend, turning an open circuit
+// half-open once OpenTimeout elapsed.
+func (b *BreakerCache) allow(ctx context.Context) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.transition(ctx, BreakerHalfOpen)
+	}
+
+	if b.trial {
+		return false
+	}
+	b.trial = true
+
+	return true
+}
+
+func (b *BreakerCache) success(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	if b.state != BreakerClosed {
+		b.transition(ctx, BreakerClosed)
+	}
+}
+
+func (b *BreakerCache) failure(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		if b.state != BreakerOpen {
+			b.transition(ctx, BreakerOpen)
+		}
+	}
+}
+
+// transition changes the state, with b.mu held.
+func (b *BreakerCache) transition(ctx context.Context, state BreakerState) {
+	b.state = state
+	instruments().breakerTransitions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("cache.backend", backendName(b.cache)),
+		attribute.String("cache.breaker.state", state.String()),
+	))
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingCache counts the calls that reach the backend.
+type countingCache struct {
+	mockCache
+	calls int
+}
+
+func (c *countingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.calls++
+	return c.mockCache.Set(ctx, key, value, ttl)
+}
+
+func (c *countingCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.calls++
+	return c.mockCache.Get(ctx, key)
+}
+
+func TestBreaker_States(t *testing.T) {
+	ctx := context.Background()
+	backendErr := errors.New("connection refused")
+	m := &countingCache{mockCache: mockCache{data: make(map[string][]byte), err: backendErr}}
+
+	now := time.Unix(0, 0)
+	b := NewBreaker(m, WithBreaker(2, time.Minute))
+	defer b.Close()
+	b.now = func() time.Time { return now }
+
+	for range 2 {
+		_, err := b.Get(ctx, "key")
+		if !errors.Is(err, ErrUnavailable) || !errors.Is(err, backendErr) {
+			t.Fatalf("expected unavailable backend error, got %v", err)
+		}
+	}
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("expected open, got %s", s)
+	}
+
+	// While open, calls do not reach the backend.
+	if _, err := b.Get(ctx, "key"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if m.calls != 2 {
+		t.Errorf("expected 2 backend calls, got %d", m.calls)
+	}
+
+	// A failed trial opens the circuit again.
+	now = now.Add(time.Minute)
+	if s := b.State(); s != BreakerHalfOpen {
+		t.Fatalf("expected half-open, got %s", s)
+	}
+	if _, err := b.Get(ctx, "key"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected the trial call to reach the backend")
+	}
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("expected open after failed trial, got %s", s)
+	}
+
+	// A successful trial closes it; misses are successes.
+	m.err = nil
+	now = now.Add(time.Minute)
+	if _, err := b.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if s := b.State(); s != BreakerClosed {
+		t.Fatalf("expected closed, got %s", s)
+	}
+}
+
+func TestBreaker_SingleTrial(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte), err: errors.New("timeout")}
+
+	now := time.Unix(0, 0)
+	b := NewBreaker(m, WithBreaker(1, time.Second))
+	defer b.Close()
+	b.now = func() time.Time { return now }
+
+	_ = b.Set(ctx, "key", []byte("v"), 0)
+	now = now.Add(time.Second)
+
+	if !b.allow(ctx) {
+		t.Fatal("expected the first call after the timeout to be a trial")
+	}
+	if b.allow(ctx) {
+		t.Error("expected a second call to be rejected while the trial is in flight")
+	}
+}
+
+func TestBreaker_CancelledIsNotFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := &mockCache{data: make(map[string][]byte), err: context.Canceled}
+
+	b := NewBreaker(m, WithBreaker(1, time.Minute))
+	defer b.Close()
+
+	if _, err := b.Get(ctx, "key"); !errors.Is(err, context.Canceled) || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected bare context.Canceled, got %v", err)
+	}
+	if s := b.State(); s != BreakerClosed {
+		t.Errorf("expected closed, got %s", s)
+	}
+}
+
+func TestBreaker_RememberFailsOpen(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte), err: errors.New("connection refused")}
+	b := NewBreaker(m, WithBreaker(1, time.Minute))
+	defer b.Close()
+
+	calls := 0
+	fn := func() (string, error) {
+		calls++
+		return "db", nil
+	}
+
+	for range 2 {
+		got, err := Remember(ctx, b, "user:1", time.Minute, fn, WithLock(time.Second), WithStale(time.Minute))
+		if err != nil {
+			t.Fatalf("Remember failed: %v", err)
+		}
+		if got != "db" {
+			t.Errorf("expected db, got %s", got)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected fn to be called on every call while the cache is down, got %d", calls)
+	}
+
+	// Without the breaker the Set error is still returned.
+	s := &setErrCache{mockCache: mockCache{data: make(map[string][]byte)}, setErr: errors.New("set error")}
+	if _, err := Remember(ctx, s, "user:1", time.Minute, fn); !errors.Is(err, s.setErr) {
+		t.Errorf("expected set error, got %v", err)
+	}
+}
+
+func TestBreaker_Passthrough(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+	b := NewBreaker(m)
+	defer b.Close()
+
+	if n, err := Incr(ctx, b, "hits", 2, time.Minute); err != nil || n != 2 {
+		t.Fatalf("expected 2, got %d (%v)", n, err)
+	}
+
+	lock, err := TryAcquireLock(ctx, b, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquireLock failed: %v", err)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+
+	if _, err := NewSlidingWindowLimiter(b, 1, time.Minute).Allow(ctx, "ip"); err != nil {
+		t.Fatalf("Allow failed: %v", err)
+	}
+
+	mock := NewBreaker(&mockCache{data: make(map[string][]byte)})
+	defer mock.Close()
+	if _, err := Incr(ctx, mock, "hits", 1, 0); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
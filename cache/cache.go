@@ -2,35 +2,135 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// ErrNotFound is returned by every backend when a key does not exist or has expired.
+var ErrNotFound = errors.New("cache: key not found")
+
 type Cache interface {
 	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
 	Get(ctx context.Context, key string) ([]byte, error)
 	Del(ctx context.Context, key string) error
 }
 
+// Pinger is implemented by backends that can check they are reachable, for
+// example from a readiness endpoint.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Adder is implemented by backends that can store a value only when the key
+// does not exist yet. It reports whether the value was stored.
+type Adder interface {
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+}
+
 type Option func(*Config)
 
 type Config struct {
 	Host     string
 	Port     int
 	Password string
-	Database int // Redis/Valkey DB number
+	Database int   // Redis/Valkey DB number
+	Codec    Codec // codec used by the generic helpers, JSON when nil
+
+	PingTimeout time.Duration // Redis: ping on creation and fail if it does not answer in time, zero skips the check; ignored by Memcached
+
+	Addrs      []string    // Redis/Valkey: host:port of every node, or of the sentinels with MasterName; overrides Host and Port
+	MasterName string      // Redis/Valkey: sentinel master set name
+	Cluster    bool        // Redis: use cluster mode even with a single address (Valkey detects it)
+	Username   string      // Redis/Valkey: ACL username
+	TLSConfig  *tls.Config // Redis/Valkey: enables TLS when set
+
+	MaxEntries      int            // Memory: maximum number of entries, zero means unbounded
+	Eviction        EvictionPolicy // Memory: entry to drop when MaxEntries is reached
+	CleanupInterval time.Duration  // Memory: how often expired entries are purged, zero disables the janitor
+
+	NearTTL     time.Duration // Tiered: TTL of in-process entries, capped by the remote TTL on writes; <= 0 means the default
+	NearFillTTL time.Duration // Tiered: TTL of in-process entries read from the remote tier, capped by NearTTL; <= 0 means the default
+	Tracking    bool          // Redis/Valkey: report keys changed by other clients to tiered caches
+
+	Namespace string // Namespaced: prefix shared by every key of a service
+	Version   int    // Namespaced: schema version, bump it when the shape of cached values changes
+
+	FailureThreshold int           // Breaker: consecutive failures that open the circuit
+	OpenTimeout      time.Duration // Breaker: how long the circuit stays open before a trial call
 }
 
-func Set[T any](ctx context.Context, c Cache, key string, value T, ttl time.Duration) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return err
+// addrs returns Addrs, or Host:Port when no address list was given.
+func (c *Config) addrs() []string {
+	if len(c.Addrs) > 0 {
+		return c.Addrs
+	}
+
+	return []string{fmt.Sprintf("%s:%d", c.Host, c.Port)}
+}
+
+// CallOption configures a single call to one of the generic helpers.
+type CallOption func(*callConfig)
+
+type callConfig struct {
+	codec    Codec
+	tags     []string
+	keyLabel *string
+
+	singleFlight bool
+	lockTTL      time.Duration
+	lockRetry    time.Duration
+	staleTTL     time.Duration
+
+	earlyRefreshBeta    float64
+	refreshErrorHandler func(key string, err error)
+
+	reverse       bool
+	offset, count int64
+}
+
+func newCallConfig(opts []CallOption) *callConfig {
+	cfg := &callConfig{
+		lockRetry: 50 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+func Set[T any](ctx context.Context, c Cache, key string, value T, ttl time.Duration, opts ...CallOption) error {
+	cfg := newCallConfig(opts)
+
+	data, err := codecFor(c, cfg).Marshal(value)
+	if err == nil {
+		err = set(ctx, c, key, data, ttl, cfg)
 	}
+	recordWrite(ctx, c, key, "set", cfg, len(data), err)
+
+	return err
+}
 
-	return c.Set(ctx, key, data, ttl)
+func Get[T any](ctx context.Context, c Cache, key string, opts ...CallOption) (T, error) {
+	return lookup[T](ctx, c, key, "get", newCallConfig(opts))
 }
 
-func Get[T any](ctx context.Context, c Cache, key string) (T, error) {
+// lookup is get with the outcome recorded in the helper metrics under operation.
+func lookup[T any](ctx context.Context, c Cache, key, operation string, cfg *callConfig) (T, error) {
+	var result T
+
+	data, err := c.Get(ctx, key)
+	if err == nil {
+		result, err = decode[T](data, codecFor(c, cfg))
+	}
+	recordRead(ctx, c, key, operation, cfg, len(data), err)
+
+	return result, err
+}
+
+func get[T any](ctx context.Context, c Cache, key string, codec Codec) (T, error) {
 	var zero T
 
 	data, err := c.Get(ctx, key)
@@ -38,8 +138,14 @@ func Get[T any](ctx context.Context, c Cache, key string) (T, error) {
 		return zero, err
 	}
 
+	return decode[T](data, codec)
+}
+
+func decode[T any](data []byte, codec Codec) (T, error) {
+	var zero T
+
 	var result T
-	if err := json.Unmarshal(data, &result); err != nil {
+	if err := codec.Unmarshal(data, &result); err != nil {
 		return zero, err
 	}
 
@@ -50,33 +156,39 @@ func Del(ctx context.Context, c Cache, key string) error {
 	return c.Del(ctx, key)
 }
 
-func Remember[T any](ctx context.Context, c Cache, key string, ttl time.Duration, fn func() (T, error)) (T, error) {
-	var zero T
-
-	data, err := c.Get(ctx, key)
-	if err == nil {
-		var result T
-		if err := json.Unmarshal(data, &result); err != nil {
-			return zero, err
-		}
-		return result, nil
+// Ping checks that c is reachable. Caches that do not implement Pinger are
+// always considered reachable.
+func Ping(ctx context.Context, c Cache) error {
+	if p, ok := c.(Pinger); ok {
+		return p.Ping(ctx)
 	}
 
-	result, err := fn()
-	if err != nil {
-		return zero, err
-	}
+	return nil
+}
 
-	bytes, err := json.Marshal(result)
-	if err != nil {
-		return zero, err
-	}
+// Unwrap returns the first cache in the chain of c, following the Unwrap
+// methods of wrappers such as TieredCache, NamespacedCache and BreakerCache,
+// that is a T or whose Client method returns a T. Use it to reach a backend,
+// as in Unwrap[*RedisCache](c), or its native client, as in
+// Unwrap[redis.UniversalClient](c).
+func Unwrap[T any](c Cache) (T, bool) {
+	for c != nil {
+		if v, ok := c.(T); ok {
+			return v, true
+		}
+		if cc, ok := c.(interface{ Client() T }); ok {
+			return cc.Client(), true
+		}
 
-	if err := c.Set(ctx, key, bytes, ttl); err != nil {
-		return zero, err
+		w, ok := c.(interface{ Unwrap() Cache })
+		if !ok {
+			break
+		}
+		c = w.Unwrap()
 	}
 
-	return result, nil
+	var zero T
+	return zero, false
 }
 
 func WithHost(host string) Option {
@@ -102,3 +214,116 @@ func WithDatabase(database int) Option {
 		c.Database = database
 	}
 }
+
+// WithAddrs sets the address of every node. With more than one address Redis
+// and Valkey connect in cluster mode, unless WithSentinel is also given.
+func WithAddrs(addrs ...string) Option {
+	return func(c *Config) {
+		c.Addrs = addrs
+	}
+}
+
+// WithSentinel connects to the primary of the given master set through the
+// sentinels listed with WithAddrs.
+func WithSentinel(masterName string) Option {
+	return func(c *Config) {
+		c.MasterName = masterName
+	}
+}
+
+func WithCluster() Option {
+	return func(c *Config) {
+		c.Cluster = true
+	}
+}
+
+func WithUsername(username string) Option {
+	return func(c *Config) {
+		c.Username = username
+	}
+}
+
+func WithTLS(config *tls.Config) Option {
+	return func(c *Config) {
+		c.TLSConfig = config
+	}
+}
+
+// WithPing makes NewRedis ping the server and fail when it does not answer
+// within timeout, the way sql.New does. NewValkey always connects on creation.
+// NewMemcached ignores it, as it cannot return an error: call Ping on the
+// cache to check the servers.
+func WithPing(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.PingTimeout = timeout
+	}
+}
+
+func WithCodec(codec Codec) Option {
+	return func(c *Config) {
+		c.Codec = codec
+	}
+}
+
+func WithMaxEntries(maxEntries int) Option {
+	return func(c *Config) {
+		c.MaxEntries = maxEntries
+	}
+}
+
+func WithEviction(eviction EvictionPolicy) Option {
+	return func(c *Config) {
+		c.Eviction = eviction
+	}
+}
+
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.CleanupInterval = interval
+	}
+}
+
+func WithNearTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.NearTTL = ttl
+	}
+}
+
+// WithNearFillTTL sets how long a tiered cache keeps in process a value it
+// read from the remote tier, whose remaining TTL it does not know (default:
+// 5s). It is capped by the near TTL.
+func WithNearFillTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.NearFillTTL = ttl
+	}
+}
+
+// WithTracking enables server-assisted client-side caching invalidation in
+// broadcast mode, so tiered caches over this backend drop keys changed by
+// other clients.
+func WithTracking() Option {
+	return func(c *Config) {
+		c.Tracking = true
+	}
+}
+
+func WithNamespace(namespace string) Option {
+	return func(c *Config) {
+		c.Namespace = namespace
+	}
+}
+
+func WithVersion(version int) Option {
+	return func(c *Config) {
+		c.Version = version
+	}
+}
+
+// WithBreaker opens the circuit after threshold consecutive failures and tries
+// the backend again after openTimeout.
+func WithBreaker(threshold int, openTimeout time.Duration) Option {
+	return func(c *Config) {
+		c.FailureThreshold = threshold
+		c.OpenTimeout = openTimeout
+	}
+}
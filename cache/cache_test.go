@@ -26,7 +26,7 @@ func (m *mockCache) Get(ctx context.Context, key string) ([]byte, error) {
 	}
 	val, ok := m.data[key]
 	if !ok {
-		return nil, errors.New("not found")
+		return nil, ErrNotFound
 	}
 	return val, nil
 }
@@ -66,8 +66,8 @@ func TestHelpers(t *testing.T) {
 
 	// Test Get failure (not found)
 	_, err = Get[User](ctx, m, "user:2")
-	if err == nil {
-		t.Fatal("expected error on missing key")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound on missing key, got %v", err)
 	}
 
 	// Test Del
@@ -174,11 +174,49 @@ func TestRemember(t *testing.T) {
 		t.Fatal("expected marshal error in Remember miss")
 	}
 
-	// 6. Set error during miss
-	m.err = errors.New("set error")
+	// 6. Backend error - returned without calling fn
+	backendErr := errors.New("backend error")
+	m.err = backendErr
+	called = 0
 	_, err = Remember(ctx, m, "user:4", time.Minute, fn)
-	if err == nil {
-		t.Fatal("expected set error in Remember miss")
+	if !errors.Is(err, backendErr) {
+		t.Fatalf("expected %v, got %v", backendErr, err)
+	}
+	if called != 0 {
+		t.Errorf("expected fn not to be called on backend error, got %d calls", called)
+	}
+
+	// 7. Set error during miss
+	s := &setErrCache{mockCache: mockCache{data: make(map[string][]byte)}, setErr: errors.New("set error")}
+	_, err = Remember(ctx, s, "user:5", time.Minute, fn)
+	if !errors.Is(err, s.setErr) {
+		t.Fatalf("expected set error in Remember miss, got %v", err)
+	}
+}
+
+type setErrCache struct {
+	mockCache
+	setErr error
+}
+
+func (s *setErrCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.setErr
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+
+	caches := map[string]Cache{
+		"Memory":     m,
+		"Tiered":     NewTiered(m, WithCleanupInterval(0)),
+		"Namespaced": NewNamespaced(m),
+		"NotPinger":  &mockCache{data: make(map[string][]byte)},
+	}
+	for name, c := range caches {
+		if err := Ping(ctx, c); err != nil {
+			t.Errorf("%s: expected Ping to succeed, got %v", name, err)
+		}
 	}
 }
 
@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec converts values to and from the bytes stored by a Cache.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSON    Codec = jsonCodec{}
+	Gob     Codec = gobCodec{}
+	MsgPack Codec = msgpackCodec{}
+	Proto   Codec = protoCodec{} // values must be proto.Message implementations
+)
+
+type Compression int
+
+const (
+	Gzip Compression = iota
+	Zstd
+)
+
+// Payloads of Compress start with one of these flags.
+const (
+	flagRaw  byte = 0
+	flagGzip byte = 1
+	flagZstd byte = 2
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("cache: %T is not a proto.Message", v)
+	}
+
+	return proto.Marshal(m)
+}
+
+// Unmarshal accepts a proto.Message, or a pointer to a nil message pointer
+// as passed by the generic helpers, in which case the message is allocated.
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Pointer {
+		return fmt.Errorf("cache: %T is not a proto.Message", v)
+	}
+
+	msg := reflect.New(rv.Elem().Type().Elem())
+	m, ok := msg.Interface().(proto.Message)
+	if !ok {
+		return fmt.Errorf("cache: %T is not a proto.Message", v)
+	}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return err
+	}
+
+	rv.Elem().Set(msg)
+	return nil
+}
+
+// Compress wraps codec so that payloads of at least threshold bytes are
+// compressed with the given algorithm. Every payload starts with a byte
+// flagging it as raw or compressed, so values written by codec alone are not
+// readable: change the namespace version when enabling compression.
+func Compress(codec Codec, compression Compression, threshold int) Codec {
+	return &compressCodec{
+		codec:       codec,
+		compression: compression,
+		threshold:   threshold,
+	}
+}
+
+type compressCodec struct {
+	codec       Codec
+	compression Compression
+	threshold   int
+}
+
+// zstd encoders and decoders are safe for concurrent use through EncodeAll
+// and DecodeAll, so one of each is shared by every codec.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func (c *compressCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.threshold {
+		return append([]byte{flagRaw}, data...), nil
+	}
+
+	switch c.compression {
+	case Gzip:
+		buf := bytes.NewBuffer([]byte{flagGzip})
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(data, []byte{flagZstd}), nil
+	default:
+		return nil, errors.New("cache: unknown compression")
+	}
+}
+
+func (c *compressCodec) Unmarshal(data []byte, v any) error {
+	if len(data) == 0 {
+		return errors.New("cache: missing compression flag")
+	}
+
+	flag, data := data[0], data[1:]
+	switch flag {
+	case flagRaw:
+	case flagGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+	case flagZstd:
+		var err error
+		data, err = zstdDecoder.DecodeAll(data, nil)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cache: unknown compression flag %d", flag)
+	}
+
+	return c.codec.Unmarshal(data, v)
+}
+
+// codecFor returns the per-call codec, then the cache's own codec, then JSON.
+func codecFor(c Cache, cfg *callConfig) Codec {
+	if cfg.codec != nil {
+		return cfg.codec
+	}
+	if cc, ok := c.(interface{ Codec() Codec }); ok && cc.Codec() != nil {
+		return cc.Codec()
+	}
+
+	return JSON
+}
+
+// WithCallCodec overrides the codec for a single call, taking precedence over
+// the codec configured on the cache.
+func WithCallCodec(codec Codec) CallOption {
+	return func(c *callConfig) {
+		c.codec = codec
+	}
+}
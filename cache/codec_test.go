@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecUser struct {
+	Name string
+	Age  int
+}
+
+func TestCodecs(t *testing.T) {
+	codecs := map[string]Codec{
+		"JSON":    JSON,
+		"Gob":     Gob,
+		"MsgPack": MsgPack,
+		"Gzip":    Compress(JSON, Gzip, 0),
+		"Zstd":    Compress(MsgPack, Zstd, 0),
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Marshal(codecUser{Name: "John", Age: 30})
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			var got codecUser
+			if err := codec.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if got.Name != "John" || got.Age != 30 {
+				t.Errorf("unexpected value %+v", got)
+			}
+		})
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	for name, codec := range map[string]Codec{"Gob": Gob, "Gzip": Compress(JSON, Gzip, 0)} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := codec.Marshal(make(chan int)); err == nil {
+				t.Error("expected marshal error")
+			}
+		})
+	}
+
+	if _, err := Compress(JSON, Compression(-1), 0).Marshal("value"); err == nil {
+		t.Error("expected error on unknown compression")
+	}
+	if err := Compress(JSON, Gzip, 0).Unmarshal([]byte{flagGzip, 0}, new(string)); err == nil {
+		t.Error("expected error on corrupt gzip payload")
+	}
+	if err := Compress(JSON, Zstd, 0).Unmarshal([]byte{flagZstd, 0}, new(string)); err == nil {
+		t.Error("expected error on corrupt zstd payload")
+	}
+	if err := Compress(JSON, Zstd, 0).Unmarshal(nil, new(string)); err == nil {
+		t.Error("expected error on a payload without flag")
+	}
+	if err := Compress(JSON, Zstd, 0).Unmarshal([]byte(`"value"`), new(string)); err == nil {
+		t.Error("expected error on an unflagged payload")
+	}
+}
+
+func TestProtoCodec(t *testing.T) {
+	data, err := Proto.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	// Plain message
+	msg := &wrapperspb.StringValue{}
+	if err := Proto.Unmarshal(data, msg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if msg.GetValue() != "hello" {
+		t.Errorf("expected hello, got %s", msg.GetValue())
+	}
+
+	// Pointer to a nil message, as passed by the generic helpers
+	var ptr *wrapperspb.StringValue
+	if err := Proto.Unmarshal(data, &ptr); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if ptr.GetValue() != "hello" {
+		t.Errorf("expected hello, got %s", ptr.GetValue())
+	}
+
+	if _, err := Proto.Marshal(codecUser{}); err == nil {
+		t.Error("expected error marshaling a non-proto value")
+	}
+	if err := Proto.Unmarshal(data, &codecUser{}); err == nil {
+		t.Error("expected error unmarshaling into a non-proto value")
+	}
+	if err := Proto.Unmarshal(data, new(*codecUser)); err == nil {
+		t.Error("expected error unmarshaling into a pointer to a non-proto value")
+	}
+	if err := Proto.Unmarshal([]byte{0xff}, &ptr); err == nil {
+		t.Error("expected error on invalid payload")
+	}
+}
+
+func TestCompressThreshold(t *testing.T) {
+	codec := Compress(JSON, Zstd, 64)
+
+	small, _ := codec.Marshal("short")
+	if !bytes.Equal(small, append([]byte{flagRaw}, `"short"`...)) {
+		t.Errorf("expected small payload to be stored uncompressed, got %q", small)
+	}
+
+	large, _ := codec.Marshal(strings.Repeat("a", 1024))
+	if large[0] != flagZstd {
+		t.Error("expected large payload to be compressed")
+	}
+	if len(large) >= 1024 {
+		t.Errorf("expected compressed payload to be smaller, got %d bytes", len(large))
+	}
+
+	var got string
+	if err := codec.Unmarshal(large, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got != strings.Repeat("a", 1024) {
+		t.Error("unexpected round trip value")
+	}
+
+	// A raw payload starting like a gzip stream is not mistaken for one.
+	gzipLike := []byte{0x1f, 0x8b, 0x08, 'x'}
+	data, _ := Compress(Members, Gzip, 64).Marshal(gzipLike)
+	var raw []byte
+	if err := Compress(Members, Gzip, 64).Unmarshal(data, &raw); err != nil || !bytes.Equal(raw, gzipLike) {
+		t.Errorf("expected %q back, got %q (%v)", gzipLike, raw, err)
+	}
+}
+
+func TestHelpersCodec(t *testing.T) {
+	ctx := context.Background()
+
+	// Codec configured on the cache
+	m := NewMemory(WithCleanupInterval(0), WithCodec(Gob))
+	defer m.Close()
+
+	if err := Set(ctx, m, "user:1", codecUser{Name: "John"}, time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	raw, _ := m.Get(ctx, "user:1")
+	if bytes.HasPrefix(raw, []byte("{")) {
+		t.Errorf("expected gob payload, got %q", raw)
+	}
+	got, err := Get[codecUser](ctx, m, "user:1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "John" {
+		t.Errorf("expected John, got %s", got.Name)
+	}
+
+	// Per-call codec takes precedence
+	if err := Set(ctx, m, "user:2", codecUser{Name: "Jane"}, time.Minute, WithCallCodec(JSON)); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	raw, _ = m.Get(ctx, "user:2")
+	if string(raw) != `{"Name":"Jane","Age":0}` {
+		t.Errorf("expected JSON payload, got %q", raw)
+	}
+
+	// Proto values through Remember
+	msg, err := Remember(ctx, m, "msg", time.Minute, func() (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("hello"), nil
+	}, WithCallCodec(Proto))
+	if err != nil || msg.GetValue() != "hello" {
+		t.Fatalf("Remember failed: %v, %v", msg, err)
+	}
+	msg, err = Get[*wrapperspb.StringValue](ctx, m, "msg", WithCallCodec(Proto))
+	if err != nil || msg.GetValue() != "hello" {
+		t.Fatalf("Get failed: %v, %v", msg, err)
+	}
+
+	// Proto values through RememberSWR
+	msg, err = RememberSWR(ctx, m, "swr", time.Minute, time.Hour, func() (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("fresh"), nil
+	}, WithCallCodec(Proto))
+	if err != nil || msg.GetValue() != "fresh" {
+		t.Fatalf("RememberSWR failed: %v, %v", msg, err)
+	}
+}
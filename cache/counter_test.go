@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+
+	if n, err := Incr(ctx, m, "hits", 2, time.Minute); err != nil || n != 2 {
+		t.Fatalf("expected 2, got %d, %v", n, err)
+	}
+	if n, _ := Incr(ctx, m, "hits", 3, time.Minute); n != 5 {
+		t.Errorf("expected 5, got %d", n)
+	}
+	if n, _ := Decr(ctx, m, "hits", 1, time.Minute); n != 4 {
+		t.Errorf("expected 4, got %d", n)
+	}
+
+	// Counters are readable with Get
+	if got, _ := Get[int](ctx, m, "hits"); got != 4 {
+		t.Errorf("expected 4, got %d", got)
+	}
+
+	_ = m.Set(ctx, "text", []byte("abc"), time.Minute)
+	if _, err := Incr(ctx, m, "text", 1, time.Minute); !errors.Is(err, errNotInteger) {
+		t.Errorf("expected %v, got %v", errNotInteger, err)
+	}
+
+	mock := &mockCache{data: make(map[string][]byte)}
+	if _, err := Incr(ctx, mock, "hits", 1, time.Minute); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := Decr(ctx, mock, "hits", 1, time.Minute); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestCounter_TTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	_, _ = m.Incr(ctx, "hits", 1, time.Minute)
+
+	// Later increments keep the expiry set on creation
+	now = now.Add(30 * time.Second)
+	_, _ = m.Incr(ctx, "hits", 1, time.Minute)
+
+	now = now.Add(31 * time.Second)
+	if n, _ := m.Incr(ctx, "hits", 1, time.Minute); n != 1 {
+		t.Errorf("expected a new counter after the ttl, got %d", n)
+	}
+}
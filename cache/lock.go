@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLockHeld is returned by TryAcquireLock when another owner holds the lock.
+	ErrLockHeld = errors.New("cache: lock is held by another owner")
+	// ErrLockLost is returned when a lock expired or was taken over before it
+	// could be extended or released.
+	ErrLockLost = errors.New("cache: lock is no longer held")
+)
+
+// Locker is implemented by backends that can change a key only while it still
+// holds the given value, which lets a lock owner extend or release its lock
+// without touching a lock that expired and was acquired by someone else.
+type Locker interface {
+	Adder
+	CompareAndExpire(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error)
+}
+
+// Lock is a distributed lock held until it is released or its TTL expires.
+// Every Lock has a random owner token, so only its holder can extend or
+// release it.
+type Lock struct {
+	locker Locker
+	key    string
+	token  []byte
+}
+
+// TryAcquireLock takes the lock at key for ttl, or returns ErrLockHeld if
+// another owner holds it.
+func TryAcquireLock(ctx context.Context, c Cache, key string, ttl time.Duration) (*Lock, error) {
+	locker, ok := c.(Locker)
+	if !ok {
+		return nil, fmt.Errorf("cache: %T cannot hold locks: %w", c, errors.ErrUnsupported)
+	}
+
+	token := lockToken()
+	acquired, err := locker.Add(ctx, key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrLockHeld
+	}
+
+	return &Lock{locker: locker, key: key, token: token}, nil
+}
+
+// AcquireLock takes the lock at key for ttl, polling every WithLockRetry
+// interval (default: 50ms) while another owner holds it, until ctx is done.
+func AcquireLock(ctx context.Context, c Cache, key string, ttl time.Duration, opts ...CallOption) (*Lock, error) {
+	cfg := newCallConfig(opts)
+
+	for {
+		l, err := TryAcquireLock(ctx, c, key, ttl)
+		if !errors.Is(err, ErrLockHeld) {
+			return l, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(cfg.lockRetry):
+		}
+	}
+}
+
+// Key returns the key the lock is held on.
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token returns the owner token stored at the lock key.
+func (l *Lock) Token() string {
+	return string(l.token)
+}
+
+// Extend resets the lock TTL to ttl, or returns ErrLockLost if the lock is no
+// longer held.
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	ok, err := l.locker.CompareAndExpire(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+
+	return nil
+}
+
+// Release frees the lock, or returns ErrLockLost if it had already expired
+// or been taken over.
+func (l *Lock) Release(ctx context.Context) error {
+	ok, err := l.locker.CompareAndDelete(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+
+	return nil
+}
+
+// releaseLock deletes the lock at key if it still holds token. Backends that
+// are not Lockers, or wrap one that is not, delete it unconditionally.
+func releaseLock(ctx context.Context, c Cache, key string, token []byte) {
+	if l, ok := c.(Locker); ok {
+		if _, err := l.CompareAndDelete(ctx, key, token); !errors.Is(err, errors.ErrUnsupported) {
+			return
+		}
+	}
+
+	_ = c.Del(ctx, key)
+}
+
+var (
+	compareAndExpireScript = newScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`, nil)
+
+	compareAndDeleteScript = newScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`, nil)
+)
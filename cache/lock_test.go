@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+
+	l, err := TryAcquireLock(ctx, m, "job:cleanup", time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquireLock failed: %v", err)
+	}
+	if l.Key() != "job:cleanup" || l.Token() == "" {
+		t.Errorf("unexpected lock %q with token %q", l.Key(), l.Token())
+	}
+
+	if _, err := TryAcquireLock(ctx, m, "job:cleanup", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("expected %v, got %v", ErrLockHeld, err)
+	}
+
+	if err := l.Extend(ctx, time.Minute); err != nil {
+		t.Errorf("Extend failed: %v", err)
+	}
+	if err := l.Release(ctx); err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+	if err := l.Release(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected %v, got %v", ErrLockLost, err)
+	}
+}
+
+func TestLock_Expired(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	l, _ := TryAcquireLock(ctx, m, "job", time.Second)
+	now = now.Add(2 * time.Second)
+
+	// Another owner takes over the expired lock
+	other, err := TryAcquireLock(ctx, m, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("expected expired lock to be acquired, got %v", err)
+	}
+
+	if err := l.Extend(ctx, time.Minute); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected %v, got %v", ErrLockLost, err)
+	}
+	if err := l.Release(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected %v, got %v", ErrLockLost, err)
+	}
+	if err := other.Release(ctx); err != nil {
+		t.Errorf("expected the new owner to keep the lock, got %v", err)
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+
+	held, _ := TryAcquireLock(ctx, m, "job", time.Minute)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = held.Release(ctx)
+	}()
+
+	l, err := AcquireLock(ctx, m, "job", time.Minute, WithLockRetry(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	defer l.Release(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := AcquireLock(ctx, m, "job", time.Minute, WithLockRetry(5*time.Millisecond)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestLock_Unsupported(t *testing.T) {
+	m := &mockCache{data: make(map[string][]byte)}
+	if _, err := AcquireLock(context.Background(), m, "job", time.Minute); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,33 +11,74 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/github.com/bradfitz/gomemcache/memcache/otelmemcache"
 )
 
+// maxRelativeExpiration is the longest expiration memcached reads as relative
+// to now; longer ones are read as a Unix time.
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
 type MemcachedCache struct {
 	client *otelmemcache.Client
+	codec  Codec
 }
 
+// NewMemcached returns a MemcachedCache over the server at Host and Port. It
+// connects lazily and ignores WithPing: call Ping to check the server.
 func NewMemcached(opts ...Option) *MemcachedCache {
 	cfg := &Config{}
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
-	client := otelmemcache.NewClientWithTracing(
-		memcache.New(fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)),
-	)
+	return newMemcached(memcache.New(fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)), cfg)
+}
+
+// NewMemcachedFromClient returns a MemcachedCache over an existing client, so
+// its connections can be shared with other libraries. Calls through the cache
+// are traced; connection options are ignored, and Close closes the client.
+func NewMemcachedFromClient(client *memcache.Client, opts ...Option) *MemcachedCache {
+	cfg := &Config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return newMemcached(client, cfg)
+}
+
+func newMemcached(client *memcache.Client, cfg *Config) *MemcachedCache {
+	return &MemcachedCache{client: otelmemcache.NewClientWithTracing(client), codec: cfg.Codec}
+}
 
-	return &MemcachedCache{client: client}
+// Client returns the instrumented gomemcache client. Use WithContext on it to
+// parent its spans.
+func (m *MemcachedCache) Client() *otelmemcache.Client {
+	return m.client
+}
+
+func (m *MemcachedCache) Codec() Codec {
+	return m.codec
+}
+
+// Ping checks that every server is reachable.
+func (m *MemcachedCache) Ping(ctx context.Context) error {
+	return m.client.WithContext(ctx).Ping()
+}
+
+func (m *MemcachedCache) Close() error {
+	return m.client.Close()
 }
 
 func (m *MemcachedCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
 	return m.client.Set(&memcache.Item{
 		Key:        key,
 		Value:      value,
-		Expiration: int32(ttl.Seconds()),
+		Expiration: expiration(ttl),
 	})
 }
 
 func (m *MemcachedCache) Get(_ context.Context, key string) ([]byte, error) {
 	item, err := m.client.Get(key)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -44,5 +87,150 @@ func (m *MemcachedCache) Get(_ context.Context, key string) ([]byte, error) {
 }
 
 func (m *MemcachedCache) Del(_ context.Context, key string) error {
-	return m.client.Delete(key)
+	if err := m.client.Delete(key); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return err
+	}
+
+	return nil
+}
+
+func (m *MemcachedCache) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	err := m.client.Add(&memcache.Item{
+		Key:        key,
+		Value:      value,
+		Expiration: expiration(ttl),
+	})
+	if errors.Is(err, memcache.ErrNotStored) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (m *MemcachedCache) GetMany(_ context.Context, keys []string) (map[string][]byte, error) {
+	items, err := m.client.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(items))
+	for key, item := range items {
+		data[key] = item.Value
+	}
+
+	return data, nil
+}
+
+// SetMany stores items one at a time, as memcached has no multi-set command.
+func (m *MemcachedCache) SetMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	for key, value := range items {
+		if err := m.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DelMany deletes keys one at a time, as memcached has no multi-delete command.
+func (m *MemcachedCache) DelMany(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := m.Del(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Incr adds delta to the counter at key. Memcached counters are unsigned, so a
+// negative delta decrements and stops at zero.
+func (m *MemcachedCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	if delta < 0 {
+		return m.Decr(ctx, key, -delta, ttl)
+	}
+
+	return m.count(ctx, key, delta, delta, ttl, m.client.Increment)
+}
+
+// Decr subtracts delta from the counter at key, stopping at zero.
+func (m *MemcachedCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	if delta < 0 {
+		return m.Incr(ctx, key, -delta, ttl)
+	}
+
+	return m.count(ctx, key, delta, 0, ttl, m.client.Decrement)
+}
+
+// count applies op, creating the counter at initial with Add when it is missing.
+func (m *MemcachedCache) count(ctx context.Context, key string, delta, initial int64, ttl time.Duration, op func(string, uint64) (uint64, error)) (int64, error) {
+	n, err := op(key, uint64(delta))
+	if !errors.Is(err, memcache.ErrCacheMiss) {
+		return int64(n), err
+	}
+
+	added, err := m.Add(ctx, key, formatInt(initial), ttl)
+	if err != nil {
+		return 0, err
+	}
+	if added {
+		return initial, nil
+	}
+
+	// Another client created the counter first, apply op to it.
+	n, err = op(key, uint64(delta))
+	return int64(n), err
+}
+
+// CompareAndExpire resets the expiry of key with a CAS write of its current
+// value, as memcached cannot touch a key conditionally.
+func (m *MemcachedCache) CompareAndExpire(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return m.compareAndSwap(key, value, expiration(ttl))
+}
+
+// CompareAndDelete expires key immediately with a CAS write, as memcached
+// cannot delete a key conditionally.
+func (m *MemcachedCache) CompareAndDelete(_ context.Context, key string, value []byte) (bool, error) {
+	return m.compareAndSwap(key, value, -1)
+}
+
+func (m *MemcachedCache) compareAndSwap(key string, value []byte, expiration int32) (bool, error) {
+	item, err := m.client.Get(key)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(item.Value, value) {
+		return false, nil
+	}
+
+	item.Expiration = expiration
+	err = m.client.CompareAndSwap(item)
+	if errors.Is(err, memcache.ErrCASConflict) || errors.Is(err, memcache.ErrNotStored) || errors.Is(err, memcache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// expiration converts ttl to a memcached expiration. Memcached counts in whole
+// seconds and reads 0 as no expiry, so a positive ttl is rounded up to at least
+// a second, and one over 30 days is sent as a Unix time.
+func expiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > maxRelativeExpiration {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+
+	return int32((ttl + time.Second - 1) / time.Second)
 }
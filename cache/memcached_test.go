@@ -1,9 +1,17 @@
 package cache
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/bradfitz/gomemcache/memcache/otelmemcache"
 )
 
 func TestMemcached(t *testing.T) {
@@ -21,4 +29,140 @@ func TestMemcached(t *testing.T) {
 	_ = m.Set(ctx, "key", []byte("value"), time.Minute)
 	_, _ = m.Get(ctx, "key")
 	_ = m.Del(ctx, "key")
+	_, _ = m.Add(ctx, "key", []byte("value"), time.Minute)
+	_, _ = m.GetMany(ctx, []string{"key"})
+	_ = m.SetMany(ctx, map[string][]byte{"key": []byte("value")}, time.Minute)
+	_ = m.DelMany(ctx, []string{"key"})
+	_, _ = m.Incr(ctx, "key", 1, time.Minute)
+	_, _ = m.Decr(ctx, "key", 1, time.Minute)
+	_, _ = m.CompareAndExpire(ctx, "key", []byte("value"), time.Minute)
+
+	if err := m.Ping(ctx); err == nil {
+		t.Error("expected Ping to fail without a server")
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestNewMemcachedFromClient(t *testing.T) {
+	client := memcache.New("127.0.0.1:1")
+	m := NewMemcachedFromClient(client, WithCodec(Gob))
+
+	if m.Client().Client != client || m.Codec() != Gob {
+		t.Error("expected the cache to wrap the given client with its codec")
+	}
+	if _, ok := Unwrap[*otelmemcache.Client](NewNamespaced(m)); !ok {
+		t.Error("expected the instrumented client through Unwrap")
+	}
+}
+
+func TestMemcached_Miss(t *testing.T) {
+	addr, stop := fakeMemcached(t)
+	defer stop()
+
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	m := NewMemcached(WithHost(host), WithPort(p))
+	ctx := context.Background()
+
+	if _, err := m.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	if err := m.Del(ctx, "key"); err != nil {
+		t.Errorf("expected nil error deleting missing key, got %v", err)
+	}
+	if ok, err := m.Add(ctx, "key", []byte("value"), time.Minute); ok || err != nil {
+		t.Errorf("expected existing key to be rejected, got %v, %v", ok, err)
+	}
+	if data, err := m.GetMany(ctx, []string{"a", "b"}); err != nil || len(data) != 0 {
+		t.Errorf("expected no hits, got %v, %v", data, err)
+	}
+	if err := m.SetMany(ctx, map[string][]byte{"a": []byte("1")}, time.Minute); err != nil {
+		t.Errorf("SetMany failed: %v", err)
+	}
+	if err := m.DelMany(ctx, []string{"a", "b"}); err != nil {
+		t.Errorf("DelMany failed: %v", err)
+	}
+
+	if ok, err := m.CompareAndDelete(ctx, "lock", []byte("token")); ok || err != nil {
+		t.Errorf("expected missing lock not to be released, got %v, %v", ok, err)
+	}
+
+	// The counter is missing but creating it loses the race every time
+	if _, err := m.Incr(ctx, "hits", 1, time.Minute); err == nil {
+		t.Error("expected Incr to fail")
+	}
+	if _, err := m.Decr(ctx, "hits", -1, time.Minute); err == nil {
+		t.Error("expected Decr to fail")
+	}
+}
+
+func TestMemcached_Expiration(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want int32
+	}{
+		{"no expiry", 0, 0},
+		{"sub-second lock", 500 * time.Millisecond, 1},
+		{"rounded up", 1500 * time.Millisecond, 2},
+		{"whole seconds", time.Minute, 60},
+		{"30 days", maxRelativeExpiration, int32(maxRelativeExpiration / time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiration(tt.ttl); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	// Longer expirations are a Unix time.
+	ttl := 60 * 24 * time.Hour
+	if got, want := int64(expiration(ttl)), time.Now().Add(ttl).Unix(); got < want-1 || got > want {
+		t.Errorf("expected Unix time %d, got %d", want, got)
+	}
+}
+
+// fakeMemcached serves a memcached text protocol endpoint where every key is missing.
+func fakeMemcached(t *testing.T) (string, func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					switch strings.Fields(scanner.Text())[0] {
+					case "gets", "get":
+						_, _ = conn.Write([]byte("END\r\n"))
+					case "delete", "incr", "decr":
+						_, _ = conn.Write([]byte("NOT_FOUND\r\n"))
+					case "set":
+						scanner.Scan() // data block
+						_, _ = conn.Write([]byte("STORED\r\n"))
+					case "add":
+						scanner.Scan() // data block
+						_, _ = conn.Write([]byte("NOT_STORED\r\n"))
+					default:
+						_, _ = conn.Write([]byte("ERROR\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), func() { _ = ln.Close() }
 }
@@ -0,0 +1,482 @@
+package cache
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"iter"
+	"sync"
+	"time"
+)
+
+// EvictionPolicy selects which entry MemoryCache drops when it is full.
+type EvictionPolicy int
+
+const (
+	EvictionLRU EvictionPolicy = iota // least recently used
+	EvictionLFU                       // least frequently used, ties broken by recency
+)
+
+type MemoryCache struct {
+	mu         sync.Mutex
+	items      map[string]*memoryEntry
+	queue      memoryQueue
+	expiries   expiryQueue
+	maxEntries int
+	codec      Codec
+	tick       uint64
+	now        func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // zero means no expiry
+
+	hits        uint64
+	lastUsed    uint64
+	index       int
+	expiryIndex int // -1 when not in the expiry queue
+}
+
+func NewMemory(opts ...Option) *MemoryCache {
+	cfg := &Config{
+		Eviction:        EvictionLRU,
+		CleanupInterval: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	m := &MemoryCache{
+		items:      make(map[string]*memoryEntry),
+		queue:      memoryQueue{policy: cfg.Eviction},
+		maxEntries: cfg.MaxEntries,
+		codec:      cfg.Codec,
+		now:        time.Now,
+		stop:       make(chan struct{}),
+	}
+
+	if cfg.CleanupInterval > 0 {
+		go m.janitor(cfg.CleanupInterval)
+	}
+
+	return m
+}
+
+func (m *MemoryCache) Codec() Codec {
+	return m.codec
+}
+
+func (m *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *MemoryCache) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+
+	m.set(key, value, ttl)
+	return true, nil
+}
+
+func (m *MemoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.lookup(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	m.tick++
+	e.hits++
+	e.lastUsed = m.tick
+	heap.Fix(&m.queue, e.index)
+
+	return clone(e.value), nil
+}
+
+func (m *MemoryCache) Del(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.items[key]; ok {
+		m.remove(e)
+	}
+
+	return nil
+}
+
+func (m *MemoryCache) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	data := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		val, err := m.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		data[key] = val
+	}
+
+	return data, nil
+}
+
+func (m *MemoryCache) SetMany(_ context.Context, items map[string][]byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, value := range items {
+		m.set(key, value, ttl)
+	}
+
+	return nil
+}
+
+func (m *MemoryCache) DelMany(_ context.Context, keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := m.items[key]; ok {
+			m.remove(e)
+		}
+	}
+
+	return nil
+}
+
+// Scan lists the keys matching pattern that existed when it was called.
+func (m *MemoryCache) Scan(_ context.Context, pattern string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		m.mu.Lock()
+		now := m.now()
+		var keys []string
+		for key, e := range m.items {
+			if !e.expired(now) && matchPattern(pattern, key) {
+				keys = append(keys, key)
+			}
+		}
+		m.mu.Unlock()
+
+		for _, key := range keys {
+			if !yield(key, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (m *MemoryCache) DelPattern(_ context.Context, pattern string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var deleted int64
+	for key, e := range m.items {
+		if matchPattern(pattern, key) {
+			if !e.expired(now) {
+				deleted++
+			}
+			m.remove(e)
+		}
+	}
+
+	return deleted, nil
+}
+
+func (m *MemoryCache) Incr(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.incr(key, delta, ttl)
+}
+
+func (m *MemoryCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return m.Incr(ctx, key, -delta, ttl)
+}
+
+func (m *MemoryCache) CompareAndExpire(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.lookup(key)
+	if !ok || !bytes.Equal(e.value, value) {
+		return false, nil
+	}
+
+	m.setExpiry(e, m.expiresAt(ttl))
+	return true, nil
+}
+
+func (m *MemoryCache) CompareAndDelete(_ context.Context, key string, value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.lookup(key)
+	if !ok || !bytes.Equal(e.value, value) {
+		return false, nil
+	}
+
+	m.remove(e)
+	return true, nil
+}
+
+func (m *MemoryCache) runScript(_ context.Context, s *script, keys []string, args ...int64) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return s.memory(m, keys, args)
+}
+
+// Len returns the number of entries held, including expired entries the
+// janitor has not collected yet.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.items)
+}
+
+// Ping always succeeds, as the cache lives in this process.
+func (m *MemoryCache) Ping(_ context.Context) error {
+	return nil
+}
+
+// Close stops the background janitor. The cache remains usable afterwards.
+func (m *MemoryCache) Close() error {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+
+	return nil
+}
+
+// clear drops every entry.
+func (m *MemoryCache) clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.items)
+	m.queue.entries = nil
+}
+
+// lookup returns the entry for key unless it is missing or expired.
+func (m *MemoryCache) lookup(key string) (*memoryEntry, bool) {
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(m.now()) {
+		m.remove(e)
+		return nil, false
+	}
+
+	return e, true
+}
+
+// incr adds delta to the integer at key, keeping its expiry, or stores delta
+// with ttl when the key is missing.
+func (m *MemoryCache) incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	e, ok := m.lookup(key)
+	if !ok {
+		m.set(key, formatInt(delta), ttl)
+		return delta, nil
+	}
+
+	n, err := parseInt(e.value)
+	if err != nil {
+		return 0, err
+	}
+
+	n += delta
+	e.value = formatInt(n)
+	return n, nil
+}
+
+// intValue returns the integer at key, or zero when the key is missing.
+func (m *MemoryCache) intValue(key string) (int64, error) {
+	e, ok := m.lookup(key)
+	if !ok {
+		return 0, nil
+	}
+
+	return parseInt(e.value)
+}
+
+func (m *MemoryCache) set(key string, value []byte, ttl time.Duration) {
+	expiresAt := m.expiresAt(ttl)
+
+	m.tick++
+	if e, ok := m.items[key]; ok {
+		e.value = clone(value)
+		e.hits++
+		e.lastUsed = m.tick
+		heap.Fix(&m.queue, e.index)
+		m.setExpiry(e, expiresAt)
+		return
+	}
+
+	if m.maxEntries > 0 && len(m.items) >= m.maxEntries {
+		m.deleteExpired()
+		for len(m.items) >= m.maxEntries {
+			m.remove(m.queue.entries[0])
+		}
+	}
+
+	e := &memoryEntry{
+		key:         key,
+		value:       clone(value),
+		hits:        1,
+		lastUsed:    m.tick,
+		expiryIndex: -1,
+	}
+	m.items[key] = e
+	heap.Push(&m.queue, e)
+	m.setExpiry(e, expiresAt)
+}
+
+func (m *MemoryCache) expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return m.now().Add(ttl)
+}
+
+// setExpiry sets the expiry of e, keeping the expiry queue in sync.
+func (m *MemoryCache) setExpiry(e *memoryEntry, expiresAt time.Time) {
+	e.expiresAt = expiresAt
+	switch {
+	case expiresAt.IsZero() && e.expiryIndex >= 0:
+		heap.Remove(&m.expiries, e.expiryIndex)
+	case expiresAt.IsZero():
+	case e.expiryIndex >= 0:
+		heap.Fix(&m.expiries, e.expiryIndex)
+	default:
+		heap.Push(&m.expiries, e)
+	}
+}
+
+func (m *MemoryCache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			m.deleteExpired()
+			m.mu.Unlock()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// deleteExpired pops the expired entries off the expiry queue, so it only
+// costs O(log n) per expired entry.
+func (m *MemoryCache) deleteExpired() {
+	now := m.now()
+	for len(m.expiries) > 0 && m.expiries[0].expired(now) {
+		m.remove(m.expiries[0])
+	}
+}
+
+func (m *MemoryCache) remove(e *memoryEntry) {
+	heap.Remove(&m.queue, e.index)
+	if e.expiryIndex >= 0 {
+		heap.Remove(&m.expiries, e.expiryIndex)
+	}
+	delete(m.items, e.key)
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
+}
+
+// memoryQueue is a min-heap whose root is the next entry to evict.
+type memoryQueue struct {
+	policy  EvictionPolicy
+	entries []*memoryEntry
+}
+
+func (q memoryQueue) Len() int { return len(q.entries) }
+
+func (q memoryQueue) Less(i, j int) bool {
+	a, b := q.entries[i], q.entries[j]
+	if q.policy == EvictionLFU && a.hits != b.hits {
+		return a.hits < b.hits
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+func (q memoryQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].index = i
+	q.entries[j].index = j
+}
+
+func (q *memoryQueue) Push(x any) {
+	e := x.(*memoryEntry)
+	e.index = len(q.entries)
+	q.entries = append(q.entries, e)
+}
+
+func (q *memoryQueue) Pop() any {
+	n := len(q.entries)
+	e := q.entries[n-1]
+	q.entries[n-1] = nil
+	q.entries = q.entries[:n-1]
+	e.index = -1
+	return e
+}
+
+// expiryQueue is a min-heap of the entries with a TTL, soonest expiry first.
+type expiryQueue []*memoryEntry
+
+func (q expiryQueue) Len() int { return len(q) }
+
+func (q expiryQueue) Less(i, j int) bool { return q[i].expiresAt.Before(q[j].expiresAt) }
+
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].expiryIndex = i
+	q[j].expiryIndex = j
+}
+
+func (q *expiryQueue) Push(x any) {
+	e := x.(*memoryEntry)
+	e.expiryIndex = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	e.expiryIndex = -1
+	return e
+}
@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	if err := m.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	val, err := m.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(val) != "value" {
+		t.Errorf("expected value, got %s", string(val))
+	}
+
+	// Returned slices must not alias the stored value
+	val[0] = 'X'
+	val, _ = m.Get(ctx, "key")
+	if string(val) != "value" {
+		t.Errorf("expected value, got %s", string(val))
+	}
+
+	if err := m.Del(ctx, "key"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	if _, err := m.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after Del, got %v", err)
+	}
+
+	// Deleting a missing key is not an error
+	if err := m.Del(ctx, "missing"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+}
+
+func TestMemory_Add(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	if ok, _ := m.Add(ctx, "key", []byte("1"), time.Second); !ok {
+		t.Fatal("expected first Add to store the value")
+	}
+	if ok, _ := m.Add(ctx, "key", []byte("2"), time.Second); ok {
+		t.Fatal("expected Add on an existing key to be rejected")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := m.Add(ctx, "key", []byte("3"), time.Second); !ok {
+		t.Fatal("expected Add on an expired key to store the value")
+	}
+
+	val, _ := m.Get(ctx, "key")
+	if string(val) != "3" {
+		t.Errorf("expected 3, got %s", string(val))
+	}
+}
+
+func TestMemory_TTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	_ = m.Set(ctx, "short", []byte("1"), time.Second)
+	_ = m.Set(ctx, "forever", []byte("2"), 0)
+
+	if _, err := m.Get(ctx, "short"); err != nil {
+		t.Fatalf("expected hit before expiry, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+
+	if _, err := m.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after expiry, got %v", err)
+	}
+	if _, err := m.Get(ctx, "forever"); err != nil {
+		t.Fatalf("expected hit for entry without ttl, got %v", err)
+	}
+	if m.Len() != 1 {
+		t.Errorf("expected expired entry to be removed, got %d entries", m.Len())
+	}
+
+	// Overwriting resets the ttl
+	_ = m.Set(ctx, "forever", []byte("3"), time.Second)
+	now = now.Add(2 * time.Second)
+	if _, err := m.Get(ctx, "forever"); err == nil {
+		t.Fatal("expected miss after overwritten ttl expired")
+	}
+}
+
+func TestMemory_LRU(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithMaxEntries(2), WithEviction(EvictionLRU), WithCleanupInterval(0))
+	defer m.Close()
+
+	_ = m.Set(ctx, "a", []byte("a"), 0)
+	_ = m.Set(ctx, "b", []byte("b"), 0)
+	_, _ = m.Get(ctx, "a")
+	_ = m.Set(ctx, "c", []byte("c"), 0)
+
+	if _, err := m.Get(ctx, "b"); err == nil {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := m.Get(ctx, key); err != nil {
+			t.Errorf("expected %s to be kept, got %v", key, err)
+		}
+	}
+}
+
+func TestMemory_LFU(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithMaxEntries(2), WithEviction(EvictionLFU), WithCleanupInterval(0))
+	defer m.Close()
+
+	_ = m.Set(ctx, "a", []byte("a"), 0)
+	_ = m.Set(ctx, "b", []byte("b"), 0)
+	_, _ = m.Get(ctx, "a")
+	_, _ = m.Get(ctx, "a")
+	_, _ = m.Get(ctx, "b")
+	_ = m.Set(ctx, "c", []byte("c"), 0)
+
+	if _, err := m.Get(ctx, "b"); err == nil {
+		t.Error("expected least frequently used entry to be evicted")
+	}
+	if _, err := m.Get(ctx, "a"); err != nil {
+		t.Errorf("expected a to be kept, got %v", err)
+	}
+}
+
+func TestMemory_EvictsExpiredFirst(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithMaxEntries(2), WithCleanupInterval(0))
+	defer m.Close()
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	_ = m.Set(ctx, "a", []byte("a"), 0)
+	_ = m.Set(ctx, "b", []byte("b"), time.Second)
+	_, _ = m.Get(ctx, "b")
+
+	now = now.Add(time.Second)
+	_ = m.Set(ctx, "c", []byte("c"), 0)
+
+	if _, err := m.Get(ctx, "a"); err != nil {
+		t.Errorf("expected live entry to be kept over expired one, got %v", err)
+	}
+}
+
+func TestMemory_ExpiryQueue(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	_ = m.Set(ctx, "a", []byte("a"), 3*time.Second)
+	_ = m.Set(ctx, "b", []byte("b"), time.Second)
+	_ = m.Set(ctx, "c", []byte("c"), 2*time.Second)
+	_ = m.Set(ctx, "d", []byte("d"), 0)
+	_ = m.Set(ctx, "c", []byte("c"), 0)                           // no longer expires
+	_, _ = m.CompareAndExpire(ctx, "a", []byte("a"), time.Second) // now expires first
+	_ = m.Del(ctx, "b")
+
+	if n := len(m.expiries); n != 1 {
+		t.Fatalf("expected 1 entry in the expiry queue, got %d", n)
+	}
+
+	now = now.Add(time.Second)
+	m.mu.Lock()
+	m.deleteExpired()
+	m.mu.Unlock()
+
+	if n := m.Len(); n != 2 || len(m.expiries) != 0 {
+		t.Errorf("expected c and d left with no pending expiry, got %d entries and %d expiries", n, len(m.expiries))
+	}
+}
+
+func TestMemory_Janitor(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(10 * time.Millisecond))
+	defer m.Close()
+
+	for i := range 10 {
+		_ = m.Set(ctx, fmt.Sprintf("key:%d", i), []byte("v"), time.Millisecond)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for m.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not purge expired entries, %d left", m.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Close is idempotent
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestMemory_Remember(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	called := 0
+	fn := func() (int, error) {
+		called++
+		return 42, nil
+	}
+
+	for range 2 {
+		got, err := Remember(ctx, m, "answer", time.Minute, fn)
+		if err != nil {
+			t.Fatalf("Remember failed: %v", err)
+		}
+		if got != 42 {
+			t.Errorf("expected 42, got %d", got)
+		}
+	}
+	if called != 1 {
+		t.Errorf("expected 1 call, got %d", called)
+	}
+}
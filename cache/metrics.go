@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "github.com/stonear/go-dev-toolkit/cache"
+
+// helperMetrics are recorded by the generic helpers. The otel backend
+// instrumentation only sees commands, not whether a Get or Remember was a hit.
+type helperMetrics struct {
+	meter metric.Meter
+
+	hits            metric.Int64Counter
+	misses          metric.Int64Counter
+	errors          metric.Int64Counter
+	computeDuration metric.Float64Histogram
+	valueSize       metric.Int64Histogram
+
+	breakerState       metric.Int64ObservableGauge
+	breakerTransitions metric.Int64Counter
+}
+
+var (
+	metricsOnce sync.Once
+	metrics     helperMetrics
+)
+
+// instruments creates the helper instruments on the global MeterProvider,
+// which forwards to the provider installed by telemetry.New even if it is
+// installed later. Instruments that fail to register are no-ops.
+func instruments() *helperMetrics {
+	metricsOnce.Do(func() {
+		meter := otel.Meter(instrumentationName)
+		metrics.meter = meter
+
+		metrics.hits, _ = meter.Int64Counter("cache.hits",
+			metric.WithDescription("Lookups by the cache helpers that found the key."))
+		metrics.misses, _ = meter.Int64Counter("cache.misses",
+			metric.WithDescription("Lookups by the cache helpers that did not find the key."))
+		metrics.errors, _ = meter.Int64Counter("cache.errors",
+			metric.WithDescription("Cache helper calls that failed for a reason other than a miss."))
+		metrics.computeDuration, _ = meter.Float64Histogram("cache.compute.duration",
+			metric.WithDescription("Duration of the functions called by Remember on a miss."),
+			metric.WithUnit("s"))
+		metrics.valueSize, _ = meter.Int64Histogram("cache.value.size",
+			metric.WithDescription("Size of the encoded values read or written by the cache helpers."),
+			metric.WithUnit("By"))
+		metrics.breakerState, _ = meter.Int64ObservableGauge("cache.breaker.state",
+			metric.WithDescription("State of the cache circuit breakers: 0 closed, 1 half-open, 2 open."))
+		metrics.breakerTransitions, _ = meter.Int64Counter("cache.breaker.transitions",
+			metric.WithDescription("State changes of the cache circuit breakers, by new state."))
+	})
+
+	return &metrics
+}
+
+// WithKeyLabel sets the label recorded as cache.key_prefix in the helper
+// metrics. By default it is the key up to its first colon ("user" for
+// "user:1"), and empty for keys without one, so full keys never become labels.
+func WithKeyLabel(label string) CallOption {
+	return func(c *callConfig) {
+		c.keyLabel = &label
+	}
+}
+
+func keyLabel(key string, cfg *callConfig) string {
+	if cfg.keyLabel != nil {
+		return *cfg.keyLabel
+	}
+
+	prefix, _, found := strings.Cut(key, ":")
+	if !found {
+		return ""
+	}
+
+	return prefix
+}
+
+// backendName names the backend of c, looking through wrappers such as
+// NamespacedCache and BreakerCache. A TieredCache is named as such, as its
+// reads may be served by either tier.
+func backendName(c Cache) string {
+	for {
+		switch c.(type) {
+		case *RedisCache:
+			return "redis"
+		case *ValkeyCache:
+			return "valkey"
+		case *MemcachedCache:
+			return "memcached"
+		case *MemoryCache:
+			return "memory"
+		case *TieredCache:
+			return "tiered"
+		}
+
+		w, ok := c.(interface{ Unwrap() Cache })
+		if !ok {
+			return fmt.Sprintf("%T", c)
+		}
+		c = w.Unwrap()
+	}
+}
+
+func metricAttrs(c Cache, key, operation string, cfg *callConfig) metric.MeasurementOption {
+	return metric.WithAttributes(
+		attribute.String("cache.backend", backendName(c)),
+		attribute.String("cache.key_prefix", keyLabel(key, cfg)),
+		attribute.String("cache.operation", operation),
+	)
+}
+
+// recordRead records a hit with the size of the value, a miss, or an error.
+func recordRead(ctx context.Context, c Cache, key, operation string, cfg *callConfig, size int, err error) {
+	m, attrs := instruments(), metricAttrs(c, key, operation, cfg)
+
+	switch {
+	case err == nil:
+		m.hits.Add(ctx, 1, attrs)
+		m.valueSize.Record(ctx, int64(size), attrs)
+	case errors.Is(err, ErrNotFound):
+		m.misses.Add(ctx, 1, attrs)
+	default:
+		m.errors.Add(ctx, 1, attrs)
+	}
+}
+
+// recordWrite records the size of a written value, or an error.
+func recordWrite(ctx context.Context, c Cache, key, operation string, cfg *callConfig, size int, err error) {
+	m, attrs := instruments(), metricAttrs(c, key, operation, cfg)
+
+	if err != nil {
+		m.errors.Add(ctx, 1, attrs)
+		return
+	}
+	m.valueSize.Record(ctx, int64(size), attrs)
+}
+
+func recordCompute(ctx context.Context, c Cache, key string, cfg *callConfig, d time.Duration) {
+	instruments().computeDuration.Record(ctx, d.Seconds(), metricAttrs(c, key, "remember", cfg))
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// The helper instruments are created once on the global provider, so every
+// test reads the same reader and filters by a key prefix of its own.
+var metricsReader = sdkmetric.NewManualReader()
+
+func init() {
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricsReader)))
+}
+
+// sumMetric adds up the counter values, or the histogram counts, of the
+// metric name recorded with the given key prefix.
+func sumMetric(t *testing.T, name, prefix string) int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := metricsReader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	match := func(attrs attribute.Set) bool {
+		v, ok := attrs.Value("cache.key_prefix")
+		return ok && v.AsString() == prefix
+	}
+
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					if match(dp.Attributes) {
+						total += dp.Value
+					}
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					if match(dp.Attributes) {
+						total += int64(dp.Count)
+					}
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					if match(dp.Attributes) {
+						total += int64(dp.Count)
+					}
+				}
+			}
+		}
+	}
+
+	return total
+}
+
+func TestMetrics_HitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte)}
+
+	if err := Set(ctx, m, "metrics-get:1", "v", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := Get[string](ctx, m, "metrics-get:1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := Get[string](ctx, m, "metrics-get:2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, _, err := GetMany[string](ctx, m, []string{"metrics-get:1", "metrics-get:3"}); err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+
+	if n := sumMetric(t, "cache.hits", "metrics-get"); n != 2 {
+		t.Errorf("expected 2 hits, got %d", n)
+	}
+	if n := sumMetric(t, "cache.misses", "metrics-get"); n != 2 {
+		t.Errorf("expected 2 misses, got %d", n)
+	}
+	// One write and two hits.
+	if n := sumMetric(t, "cache.value.size", "metrics-get"); n != 3 {
+		t.Errorf("expected 3 sizes, got %d", n)
+	}
+}
+
+func TestMetrics_Remember(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte)}
+
+	fn := func() (int, error) { return 1, nil }
+	for range 3 {
+		if _, err := Remember(ctx, m, "metrics-remember:1", time.Minute, fn); err != nil {
+			t.Fatalf("Remember failed: %v", err)
+		}
+	}
+
+	if n := sumMetric(t, "cache.hits", "metrics-remember"); n != 2 {
+		t.Errorf("expected 2 hits, got %d", n)
+	}
+	if n := sumMetric(t, "cache.misses", "metrics-remember"); n != 1 {
+		t.Errorf("expected 1 miss, got %d", n)
+	}
+	if n := sumMetric(t, "cache.compute.duration", "metrics-remember"); n != 1 {
+		t.Errorf("expected 1 computation, got %d", n)
+	}
+}
+
+func TestMetrics_Errors(t *testing.T) {
+	ctx := context.Background()
+	s := &setErrCache{mockCache: mockCache{data: make(map[string][]byte)}, setErr: errors.New("set error")}
+
+	if err := Set(ctx, s, "metrics-errors:1", "v", time.Minute); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if n := sumMetric(t, "cache.errors", "metrics-errors"); n != 1 {
+		t.Errorf("expected 1 error, got %d", n)
+	}
+}
+
+func TestMetrics_KeyLabel(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte)}
+
+	if _, err := Get[string](ctx, m, "user-42-profile", WithKeyLabel("metrics-label")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n := sumMetric(t, "cache.misses", "metrics-label"); n != 1 {
+		t.Errorf("expected 1 miss, got %d", n)
+	}
+
+	cfg := newCallConfig(nil)
+	if got := keyLabel("user-42-profile", cfg); got != "" {
+		t.Errorf("expected empty label for key without prefix, got %q", got)
+	}
+	if got := keyLabel("user:42:profile", cfg); got != "user" {
+		t.Errorf("expected user, got %q", got)
+	}
+}
+
+func TestBackendName(t *testing.T) {
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	tests := []struct {
+		name string
+		c    Cache
+		want string
+	}{
+		{"backend", m, "memory"},
+		{"namespaced", NewNamespaced(m, WithNamespace("orders")), "memory"},
+		{"breaker over namespaced", NewBreaker(NewNamespaced(m, WithNamespace("orders"))), "memory"},
+		{"tiered", NewBreaker(NewTiered(m)), "tiered"},
+		{"unknown", &mockCache{}, "*cache.mockCache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backendName(tt.c); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
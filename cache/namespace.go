@@ -0,0 +1,428 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tagger is implemented by caches that can group entries under tags and
+// invalidate every entry of a tag at once.
+type Tagger interface {
+	SetTagged(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+var errMalformedEntry = errors.New("cache: malformed namespaced entry")
+
+// tagKeyspace starts the keys of every tag version, whatever the namespace.
+const tagKeyspace = "#tags:"
+
+// NamespacedCache prefixes every key with a namespace and schema version, so
+// services sharing a backend do not collide and a deploy that bumps the
+// version never decodes values written in an older shape.
+//
+// Entries can be tagged with SetTagged (or the WithTags call option). Each tag
+// has a version stored in the underlying cache next to the entries; an entry
+// remembers the versions of its tags when it is written and is treated as a
+// miss once any of them changed, so InvalidateTags only touches the tag keys.
+type NamespacedCache struct {
+	cache     Cache
+	prefix    string
+	tagPrefix string
+}
+
+func NewNamespaced(c Cache, opts ...Option) *NamespacedCache {
+	cfg := &Config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var prefix string
+	if cfg.Namespace != "" {
+		prefix = cfg.Namespace + ":"
+	}
+
+	// Tag versions live outside the entries, under "#tags:" followed by the
+	// length of the namespace and the namespace itself, so no namespace and
+	// tag pair shares a key with another. Memcached accepts "#" unlike
+	// control characters. Namespaces starting with "#tags" and, without a
+	// namespace, keys starting with "#tags:" are reserved.
+	n := &NamespacedCache{
+		cache:     c,
+		prefix:    prefix,
+		tagPrefix: tagKeyspace + strconv.Itoa(len(cfg.Namespace)) + ":" + cfg.Namespace + ":",
+	}
+	if cfg.Version > 0 {
+		n.prefix += fmt.Sprintf("v%d:", cfg.Version)
+	}
+
+	return n
+}
+
+// Unwrap returns the underlying cache.
+func (n *NamespacedCache) Unwrap() Cache {
+	return n.cache
+}
+
+// Codec returns the codec of the underlying cache.
+func (n *NamespacedCache) Codec() Codec {
+	if cc, ok := n.cache.(interface{ Codec() Codec }); ok {
+		return cc.Codec()
+	}
+
+	return nil
+}
+
+func (n *NamespacedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return n.cache.Set(ctx, n.key(key), encodeTagged(nil, value), ttl)
+}
+
+func (n *NamespacedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := n.cache.Get(ctx, n.key(key))
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := n.validate(ctx, map[string][]byte{key: data})
+	if err != nil {
+		return nil, err
+	}
+
+	val, ok := values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return val, nil
+}
+
+func (n *NamespacedCache) Del(ctx context.Context, key string) error {
+	return n.cache.Del(ctx, n.key(key))
+}
+
+// Add stores value only when the key does not exist yet. It returns
+// errors.ErrUnsupported when the underlying cache is not an Adder.
+func (n *NamespacedCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	a, ok := n.cache.(Adder)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+
+	return a.Add(ctx, n.key(key), encodeTagged(nil, value), ttl)
+}
+
+// CompareAndExpire resets the TTL of key if it holds value, so locks can be
+// taken on a namespaced cache. It returns errors.ErrUnsupported when the
+// underlying cache is not a Locker.
+func (n *NamespacedCache) CompareAndExpire(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	l, ok := n.cache.(Locker)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+
+	return l.CompareAndExpire(ctx, n.key(key), encodeTagged(nil, value), ttl)
+}
+
+// CompareAndDelete deletes key if it holds value. It returns
+// errors.ErrUnsupported when the underlying cache is not a Locker.
+func (n *NamespacedCache) CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error) {
+	l, ok := n.cache.(Locker)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+
+	return l.CompareAndDelete(ctx, n.key(key), encodeTagged(nil, value))
+}
+
+func (n *NamespacedCache) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	data, err := getMany(ctx, n.cache, n.keys(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string][]byte, len(data))
+	for _, key := range keys {
+		if val, ok := data[n.key(key)]; ok {
+			raw[key] = val
+		}
+	}
+
+	return n.validate(ctx, raw)
+}
+
+func (n *NamespacedCache) SetMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	data := make(map[string][]byte, len(items))
+	for key, value := range items {
+		data[n.key(key)] = encodeTagged(nil, value)
+	}
+
+	return setMany(ctx, n.cache, data, ttl)
+}
+
+func (n *NamespacedCache) DelMany(ctx context.Context, keys []string) error {
+	return DelMany(ctx, n.cache, n.keys(keys))
+}
+
+// Scan lists the keys of the namespace matching pattern, without their
+// prefix. Tag versions are not listed.
+func (n *NamespacedCache) Scan(ctx context.Context, pattern string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for key, err := range Scan(ctx, n.cache, escapePattern(n.prefix)+pattern) {
+			if err != nil {
+				yield("", err)
+				return
+			}
+			if strings.HasPrefix(key, tagKeyspace) {
+				continue
+			}
+			if !yield(strings.TrimPrefix(key, n.prefix), nil) {
+				return
+			}
+		}
+	}
+}
+
+// DelPattern deletes the keys of the namespace matching pattern. It returns
+// the number of keys it tried to delete, which may include keys that expired
+// or were deleted meanwhile.
+func (n *NamespacedCache) DelPattern(ctx context.Context, pattern string) (int64, error) {
+	return delScanned(n.Scan(ctx, pattern), func(batch []string) (int64, error) {
+		return int64(len(batch)), n.DelMany(ctx, batch)
+	})
+}
+
+// Ping checks the underlying cache.
+func (n *NamespacedCache) Ping(ctx context.Context) error {
+	return Ping(ctx, n.cache)
+}
+
+// SetTagged stores value under key and tags, creating a version for the tags
+// that have none yet.
+func (n *NamespacedCache) SetTagged(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	versions, err := getMany(ctx, n.cache, n.tagKeys(tags))
+	if err != nil {
+		return err
+	}
+
+	stamps := make([]tagStamp, 0, len(tags))
+	for _, tag := range tags {
+		version, ok := versions[n.tagKey(tag)]
+		if !ok {
+			if version, err = n.createTagVersion(ctx, tag); err != nil {
+				return err
+			}
+		}
+		stamps = append(stamps, tagStamp{tag: tag, version: version})
+	}
+
+	return n.cache.Set(ctx, n.key(key), encodeTagged(stamps, value), ttl)
+}
+
+// createTagVersion creates the first version of tag. It is added rather than
+// set so that concurrent writers agree on one version instead of resetting
+// each other's, which would turn their entries into misses.
+func (n *NamespacedCache) createTagVersion(ctx context.Context, tag string) ([]byte, error) {
+	// Tag versions never expire; if the backend evicts one, the entries
+	// stamped with it simply become misses.
+	key, version := n.tagKey(tag), []byte(rand.Text())
+
+	a, ok := n.cache.(Adder)
+	if !ok {
+		return version, n.cache.Set(ctx, key, version, 0)
+	}
+
+	added, err := a.Add(ctx, key, version, 0)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return version, n.cache.Set(ctx, key, version, 0)
+	}
+	if err != nil || added {
+		return version, err
+	}
+
+	current, err := n.cache.Get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		// Invalidated meanwhile: the entry is a miss either way.
+		return version, nil
+	}
+
+	return current, err
+}
+
+// InvalidateTags turns every entry stored under any of tags into a miss.
+func (n *NamespacedCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	return DelMany(ctx, n.cache, n.tagKeys(tags))
+}
+
+func (n *NamespacedCache) key(key string) string {
+	return n.prefix + key
+}
+
+func (n *NamespacedCache) keys(keys []string) []string {
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = n.key(key)
+	}
+
+	return out
+}
+
+func (n *NamespacedCache) tagKey(tag string) string {
+	return n.tagPrefix + tag
+}
+
+func (n *NamespacedCache) tagKeys(tags []string) []string {
+	out := make([]string, len(tags))
+	for i, tag := range tags {
+		out[i] = n.tagKey(tag)
+	}
+
+	return out
+}
+
+// validate decodes raw entries and drops those whose tags were invalidated
+// since they were written, reading the versions of all tags at once.
+func (n *NamespacedCache) validate(ctx context.Context, raw map[string][]byte) (map[string][]byte, error) {
+	entries := make(map[string]taggedEntry, len(raw))
+	var tags []string
+	seen := make(map[string]bool)
+	for key, data := range raw {
+		e, err := decodeTagged(data)
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = e
+
+		for _, s := range e.stamps {
+			if !seen[s.tag] {
+				seen[s.tag] = true
+				tags = append(tags, s.tag)
+			}
+		}
+	}
+
+	var versions map[string][]byte
+	if len(tags) > 0 {
+		var err error
+		if versions, err = getMany(ctx, n.cache, n.tagKeys(tags)); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make(map[string][]byte, len(entries))
+	for key, e := range entries {
+		if e.current(func(tag string) []byte { return versions[n.tagKey(tag)] }) {
+			values[key] = e.value
+		}
+	}
+
+	return values, nil
+}
+
+type tagStamp struct {
+	tag     string
+	version []byte
+}
+
+type taggedEntry struct {
+	stamps []tagStamp
+	value  []byte
+}
+
+func (e taggedEntry) current(version func(tag string) []byte) bool {
+	for _, s := range e.stamps {
+		v := version(s.tag)
+		if v == nil || string(v) != string(s.version) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encodeTagged prefixes value with the number of stamps followed by each tag
+// and its version, all length-prefixed with uvarints.
+func encodeTagged(stamps []tagStamp, value []byte) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(stamps)))
+	for _, s := range stamps {
+		buf = binary.AppendUvarint(buf, uint64(len(s.tag)))
+		buf = append(buf, s.tag...)
+		buf = binary.AppendUvarint(buf, uint64(len(s.version)))
+		buf = append(buf, s.version...)
+	}
+
+	return append(buf, value...)
+}
+
+func decodeTagged(data []byte) (taggedEntry, error) {
+	var e taggedEntry
+
+	next := func() ([]byte, bool) {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || uint64(len(data)-size) < n {
+			return nil, false
+		}
+		field := data[size : size+int(n)]
+		data = data[size+int(n):]
+		return field, true
+	}
+
+	count, size := binary.Uvarint(data)
+	if size <= 0 || count > uint64(len(data)) {
+		return e, errMalformedEntry
+	}
+	data = data[size:]
+
+	for range count {
+		tag, ok := next()
+		if !ok {
+			return e, errMalformedEntry
+		}
+		version, ok := next()
+		if !ok {
+			return e, errMalformedEntry
+		}
+		e.stamps = append(e.stamps, tagStamp{tag: string(tag), version: version})
+	}
+	e.value = data
+
+	return e, nil
+}
+
+// InvalidateTags invalidates every entry stored under any of tags.
+func InvalidateTags(ctx context.Context, c Cache, tags ...string) error {
+	t, ok := c.(Tagger)
+	if !ok {
+		return fmt.Errorf("cache: %T does not support tags: %w", c, errors.ErrUnsupported)
+	}
+
+	return t.InvalidateTags(ctx, tags...)
+}
+
+// WithTags stores the value written by Set, SetMany or Remember under tags, so
+// it can be invalidated with InvalidateTags. The cache must implement Tagger.
+func WithTags(tags ...string) CallOption {
+	return func(c *callConfig) {
+		c.tags = append(c.tags, tags...)
+	}
+}
+
+// set stores data under key, tagging it when the call has tags.
+func set(ctx context.Context, c Cache, key string, data []byte, ttl time.Duration, cfg *callConfig) error {
+	if len(cfg.tags) == 0 {
+		return c.Set(ctx, key, data, ttl)
+	}
+
+	t, ok := c.(Tagger)
+	if !ok {
+		return fmt.Errorf("cache: %T does not support tags: %w", c, errors.ErrUnsupported)
+	}
+
+	return t.SetTagged(ctx, key, data, ttl, cfg.tags)
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamespaced(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	c := NewNamespaced(m, WithNamespace("orders"), WithVersion(2))
+
+	if err := Set(ctx, c, "user:1", "John", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := m.Get(ctx, "orders:v2:user:1"); err != nil {
+		t.Errorf("expected prefixed key in the underlying cache, got %v", err)
+	}
+
+	got, err := Get[string](ctx, c, "user:1")
+	if err != nil || got != "John" {
+		t.Fatalf("expected John, got %q, %v", got, err)
+	}
+
+	// A new schema version does not see entries of the previous one
+	v3 := NewNamespaced(m, WithNamespace("orders"), WithVersion(3))
+	if _, err := Get[string](ctx, v3, "user:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound across versions, got %v", err)
+	}
+
+	if err := Del(ctx, c, "user:1"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	if _, err := Get[string](ctx, c, "user:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after Del, got %v", err)
+	}
+
+	if added, err := c.Add(ctx, "lock", []byte("token"), time.Minute); !added || err != nil {
+		t.Errorf("expected Add to store the key, got %v, %v", added, err)
+	}
+	if added, _ := c.Add(ctx, "lock", []byte("token"), time.Minute); added {
+		t.Error("expected Add to fail on an existing key")
+	}
+}
+
+func TestNamespaced_Tags(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	c := NewNamespaced(m, WithNamespace("orders"))
+
+	_ = Set(ctx, c, "order:1", "a", time.Minute, WithTags("tenant:42"))
+	_ = Set(ctx, c, "order:2", "b", time.Minute, WithTags("tenant:42", "region:eu"))
+	_ = Set(ctx, c, "order:3", "c", time.Minute, WithTags("tenant:7"))
+	_ = Set(ctx, c, "order:4", "d", time.Minute)
+
+	if err := InvalidateTags(ctx, c, "tenant:42"); err != nil {
+		t.Fatalf("InvalidateTags failed: %v", err)
+	}
+
+	hits, misses, err := GetMany[string](ctx, c, []string{"order:1", "order:2", "order:3", "order:4"})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+	if len(hits) != 2 || hits["order:3"] != "c" || hits["order:4"] != "d" {
+		t.Errorf("expected only untouched entries, got %v", hits)
+	}
+	if len(misses) != 2 {
+		t.Errorf("expected invalidated entries to be misses, got %v", misses)
+	}
+	if _, err := Get[string](ctx, c, "order:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Entries written after the invalidation are valid again
+	_ = Set(ctx, c, "order:1", "a2", time.Minute, WithTags("tenant:42"))
+	if got, err := Get[string](ctx, c, "order:1"); err != nil || got != "a2" {
+		t.Errorf("expected a2, got %q, %v", got, err)
+	}
+}
+
+func TestNamespaced_RememberTags(t *testing.T) {
+	ctx := context.Background()
+	c := NewNamespaced(NewMemory(WithCleanupInterval(0)))
+
+	calls := 0
+	fn := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	_, _ = Remember(ctx, c, "key", time.Minute, fn, WithTags("group"))
+	_, _ = Remember(ctx, c, "key", time.Minute, fn, WithTags("group"))
+	_ = InvalidateTags(ctx, c, "group")
+	got, _ := Remember(ctx, c, "key", time.Minute, fn, WithTags("group"))
+
+	if calls != 2 || got != 2 {
+		t.Errorf("expected a recompute after invalidation only, got %d calls and %d", calls, got)
+	}
+
+	if err := SetMany(ctx, c, map[string]int{"a": 1, "b": 2}, time.Minute, WithTags("batch")); err != nil {
+		t.Fatalf("SetMany failed: %v", err)
+	}
+	_ = InvalidateTags(ctx, c, "batch")
+	if hits, _, _ := GetMany[int](ctx, c, []string{"a", "b"}); len(hits) != 0 {
+		t.Errorf("expected tagged batch to be invalidated, got %v", hits)
+	}
+}
+
+func TestNamespaced_Errors(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte)}
+
+	if err := Set(ctx, m, "key", "value", time.Minute, WithTags("tag")); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if err := InvalidateTags(ctx, m, "tag"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+
+	c := NewNamespaced(m)
+	if _, err := c.Add(ctx, "key", []byte("value"), time.Minute); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported without an Adder, got %v", err)
+	}
+
+	m.data["corrupt"] = []byte{0x05}
+	if _, err := c.Get(ctx, "corrupt"); !errors.Is(err, errMalformedEntry) {
+		t.Errorf("expected %v, got %v", errMalformedEntry, err)
+	}
+
+	wantErr := errors.New("backend error")
+	m.err = wantErr
+	if err := c.SetTagged(ctx, "key", []byte("value"), time.Minute, []string{"tag"}); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if _, err := c.GetMany(ctx, []string{"key"}); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+}
+
+func TestDecodeTagged(t *testing.T) {
+	stamps := []tagStamp{{tag: "a", version: []byte("1")}, {tag: "bb", version: []byte("22")}}
+	e, err := decodeTagged(encodeTagged(stamps, []byte("value")))
+	if err != nil {
+		t.Fatalf("decodeTagged failed: %v", err)
+	}
+	if len(e.stamps) != 2 || e.stamps[1].tag != "bb" || string(e.stamps[1].version) != "22" || string(e.value) != "value" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	for _, data := range [][]byte{nil, {0x01, 0x05, 'a'}, {0x01, 0x01, 'a', 0x09}} {
+		if _, err := decodeTagged(data); !errors.Is(err, errMalformedEntry) {
+			t.Errorf("expected %v for %v, got %v", errMalformedEntry, data, err)
+		}
+	}
+}
+
+func TestNamespaced_TagKeyspace(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+
+	for _, c := range []*NamespacedCache{NewNamespaced(m), NewNamespaced(m, WithNamespace("orders"))} {
+		_ = Set(ctx, c, "order:1", "a", time.Minute, WithTags("x"))
+
+		// A user key that looks like a tag neither touches the tag version
+		// nor is hidden from Scan.
+		_ = Set(ctx, c, "tag:x", "user", time.Minute)
+		if got, err := Get[string](ctx, c, "order:1"); err != nil || got != "a" {
+			t.Errorf("expected the tagged entry to stay valid, got %q (%v)", got, err)
+		}
+
+		var keys []string
+		for key, err := range c.Scan(ctx, "*") {
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			keys = append(keys, key)
+		}
+		if !slices.Contains(keys, "tag:x") || slices.ContainsFunc(keys, func(k string) bool { return strings.Contains(k, "#tags:") }) {
+			t.Errorf("expected user keys without tag versions, got %v", keys)
+		}
+	}
+}
+
+func TestNamespaced_CreateTagVersion(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	c := NewNamespaced(m, WithNamespace("orders"))
+
+	// Another writer created the version between our read and our write.
+	_ = m.Set(ctx, c.tagKey("x"), []byte("theirs"), 0)
+	version, err := c.createTagVersion(ctx, "x")
+	if err != nil || string(version) != "theirs" {
+		t.Errorf("expected the existing version, got %q (%v)", version, err)
+	}
+	if current, _ := m.Get(ctx, c.tagKey("x")); string(current) != "theirs" {
+		t.Errorf("expected the version not to be reset, got %q", current)
+	}
+}
+
+func TestNamespaced_TagKeysDoNotCollide(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	a := NewNamespaced(m, WithNamespace("a"))
+	other := NewNamespaced(m, WithNamespace("a#tags"))
+
+	_ = Set(ctx, other, "x", "entry", time.Minute)
+	_ = Set(ctx, a, "order:1", "tagged", time.Minute, WithTags("x"))
+	if err := a.InvalidateTags(ctx, "x"); err != nil {
+		t.Fatalf("InvalidateTags failed: %v", err)
+	}
+
+	if got, err := Get[string](ctx, other, "x"); err != nil || got != "entry" {
+		t.Errorf("expected the entry of the other namespace to be kept, got %q (%v)", got, err)
+	}
+}
+
+func TestNamespaced_Locker(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	c := NewNamespaced(m, WithNamespace("jobs"))
+
+	lock, err := TryAcquireLock(ctx, c, "report", time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquireLock failed: %v", err)
+	}
+
+	// Another owner's token cannot release the lock.
+	releaseLock(ctx, c, "report", []byte("other"))
+	if _, err := TryAcquireLock(ctx, c, "report", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("expected the lock to stay held, got %v", err)
+	}
+
+	if err := lock.Extend(ctx, time.Hour); err != nil {
+		t.Errorf("Extend failed: %v", err)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+	if _, err := m.Get(ctx, "jobs:report"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the prefixed lock key to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RateLimiter decides whether the caller identified by key may proceed.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+	AllowN(ctx context.Context, key string, n int64) (RateLimitResult, error)
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int64         // requests left in the current window or bucket
+	RetryAfter time.Duration // how long to wait before retrying, zero when allowed
+}
+
+// FixedWindowLimiter allows limit requests per key in each window, with
+// windows aligned to the Unix epoch. It works on any Counter backend.
+type FixedWindowLimiter struct {
+	cache  Cache
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewFixedWindowLimiter(c Cache, limit int64, window time.Duration) *FixedWindowLimiter {
+	return &FixedWindowLimiter{cache: c, limit: limit, window: window, now: time.Now}
+}
+
+func (l *FixedWindowLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN counts n requests. Denied requests are counted as well, so callers
+// that keep retrying within a window stay limited.
+func (l *FixedWindowLimiter) AllowN(ctx context.Context, key string, n int64) (RateLimitResult, error) {
+	now := l.now()
+	// Windows are counted in whole milliseconds, at least one.
+	window := max(1, l.window.Milliseconds())
+	index := now.UnixMilli() / window
+
+	count, err := Incr(ctx, l.cache, fmt.Sprintf("%s:%d", key, index), n, time.Duration(window)*time.Millisecond)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	result := RateLimitResult{
+		Allowed:   count <= l.limit,
+		Remaining: max(0, l.limit-count),
+	}
+	if !result.Allowed {
+		result.RetryAfter = time.UnixMilli((index + 1) * window).Sub(now)
+	}
+
+	return result, nil
+}
+
+// SlidingWindowLimiter allows limit requests per key in any window-long
+// period, estimated from the counts of the current and previous fixed windows
+// weighted by their overlap with the period. It needs a backend that runs
+// scripts: Redis, Valkey or the memory backend.
+type SlidingWindowLimiter struct {
+	cache  Cache
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewSlidingWindowLimiter(c Cache, limit int64, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{cache: c, limit: limit, window: window, now: time.Now}
+}
+
+func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN counts n requests if they fit in the limit. Denied requests are not
+// counted.
+func (l *SlidingWindowLimiter) AllowN(ctx context.Context, key string, n int64) (RateLimitResult, error) {
+	// Windows are counted in whole milliseconds, at least one.
+	window := max(1, l.window.Milliseconds())
+
+	res, err := runScript(ctx, l.cache, slidingWindowScript, []string{key}, l.limit, window, l.now().UnixMilli(), n)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    res[0] == 1,
+		Remaining:  max(0, res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// TokenBucketLimiter gives each key a bucket of capacity tokens that refills
+// by one token every interval; each request takes one token. It allows
+// bursts up to capacity and needs a backend that runs scripts: Redis, Valkey
+// or the memory backend.
+type TokenBucketLimiter struct {
+	cache    Cache
+	capacity int64
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewTokenBucketLimiter(c Cache, capacity int64, interval time.Duration) *TokenBucketLimiter {
+	return &TokenBucketLimiter{cache: c, capacity: capacity, interval: interval, now: time.Now}
+}
+
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN takes n tokens if the bucket holds enough of them.
+func (l *TokenBucketLimiter) AllowN(ctx context.Context, key string, n int64) (RateLimitResult, error) {
+	interval := max(1, l.interval.Milliseconds())
+
+	res, err := runScript(ctx, l.cache, tokenBucketScript, []string{key}, l.capacity, interval, l.now().UnixMilli(), n)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    res[0] == 1,
+		Remaining:  res[1],
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// slidingWindowScript takes KEYS window state and ARGV limit, window in
+// milliseconds, current time in milliseconds and cost. The state stores the
+// index of the current window with its count and the count of the previous
+// one. It returns whether the request was allowed, the requests left and the
+// milliseconds until the previous window stops counting.
+//
+// Redis and Valkey read the time from their own clock, so replicas with
+// skewed clocks share the same windows; the time argument only drives the
+// memory backend.
+var slidingWindowScript = newScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[4])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local index = math.floor(now / window)
+local elapsed = now - index * window
+local state = redis.call('HMGET', KEYS[1], 'index', 'curr', 'prev')
+local stored = tonumber(state[1])
+local curr, prev = 0, 0
+if stored == index then
+	curr, prev = tonumber(state[2]), tonumber(state[3])
+elseif stored == index - 1 then
+	prev = tonumber(state[2])
+end
+local estimate = math.floor(prev * (window - elapsed) / window) + curr
+if estimate + cost > limit then
+	return {0, limit - estimate, window - elapsed}
+end
+redis.call('HSET', KEYS[1], 'index', index, 'curr', curr + cost, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {1, limit - estimate - cost, 0}
+`, func(m *MemoryCache, keys []string, args []int64) ([]int64, error) {
+	limit, window, now, cost := args[0], args[1], args[2], args[3]
+	index := now / window
+	elapsed := now - index*window
+
+	var stored, curr, prev int64
+	if e, ok := m.lookup(keys[0]); ok {
+		if _, err := fmt.Sscanf(string(e.value), "%d %d %d", &stored, &curr, &prev); err != nil {
+			return nil, errNotInteger
+		}
+	}
+	switch stored {
+	case index:
+	case index - 1:
+		curr, prev = 0, curr
+	default:
+		curr, prev = 0, 0
+	}
+
+	estimate := prev*(window-elapsed)/window + curr
+	if estimate+cost > limit {
+		return []int64{0, limit - estimate, window - elapsed}, nil
+	}
+
+	m.set(keys[0], fmt.Appendf(nil, "%d %d %d", index, curr+cost, prev), time.Duration(window*2)*time.Millisecond)
+
+	return []int64{1, limit - estimate - cost, 0}, nil
+})
+
+// tokenBucketScript takes KEYS bucket and ARGV capacity, refill interval in
+// milliseconds, current time in milliseconds and cost. The bucket stores the
+// tokens left and when they were last refilled; it expires once it would be
+// full again. It returns whether the request was allowed, the tokens left and
+// the milliseconds until enough tokens are available. Like
+// slidingWindowScript, it reads the time from the server on Redis and Valkey.
+var tokenBucketScript = newScript(`
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local cost = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+if now > ts then
+	local refill = math.floor((now - ts) / interval)
+	tokens = math.min(capacity, tokens + refill)
+	ts = ts + refill * interval
+	if tokens == capacity then
+		ts = now
+	end
+end
+local allowed, retry = 0, 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = (cost - tokens) * interval - (now - ts)
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], (capacity - tokens) * interval + 1)
+return {allowed, tokens, retry}
+`, func(m *MemoryCache, keys []string, args []int64) ([]int64, error) {
+	capacity, interval, now, cost := args[0], args[1], args[2], args[3]
+
+	tokens, ts := capacity, now
+	if e, ok := m.lookup(keys[0]); ok {
+		if _, err := fmt.Sscanf(string(e.value), "%d %d", &tokens, &ts); err != nil {
+			return nil, errNotInteger
+		}
+	}
+
+	if now > ts {
+		refill := (now - ts) / interval
+		tokens = min(capacity, tokens+refill)
+		ts += refill * interval
+		if tokens == capacity {
+			ts = now
+		}
+	}
+
+	var allowed, retry int64
+	if tokens >= cost {
+		tokens -= cost
+		allowed = 1
+	} else {
+		retry = (cost-tokens)*interval - (now - ts)
+	}
+
+	m.set(keys[0], fmt.Appendf(nil, "%d %d", tokens, ts), time.Duration((capacity-tokens)*interval+1)*time.Millisecond)
+
+	return []int64{allowed, tokens, retry}, nil
+})
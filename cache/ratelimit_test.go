@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFixedWindowLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := NewFixedWindowLimiter(NewMemory(WithCleanupInterval(0)), 2, time.Minute)
+	now := time.UnixMilli(10 * time.Minute.Milliseconds())
+	l.now = func() time.Time { return now }
+
+	for i, want := range []bool{true, true, false} {
+		res, err := l.Allow(ctx, "user:1")
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if res.Allowed != want {
+			t.Errorf("request %d: expected allowed %v, got %v", i, want, res.Allowed)
+		}
+	}
+
+	now = now.Add(45 * time.Second)
+	res, _ := l.Allow(ctx, "user:1")
+	if res.Allowed || res.RetryAfter != 15*time.Second {
+		t.Errorf("expected denial for 15s, got %+v", res)
+	}
+
+	// A new window starts from zero
+	now = now.Add(15 * time.Second)
+	if res, _ := l.Allow(ctx, "user:1"); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("expected allowed with 1 remaining, got %+v", res)
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := NewSlidingWindowLimiter(NewMemory(WithCleanupInterval(0)), 4, time.Minute)
+	now := time.UnixMilli(10 * time.Minute.Milliseconds())
+	l.now = func() time.Time { return now }
+
+	if res, _ := l.AllowN(ctx, "user:1", 4); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected allowed with 0 remaining, got %+v", res)
+	}
+	if res, _ := l.Allow(ctx, "user:1"); res.Allowed {
+		t.Errorf("expected denial, got %+v", res)
+	}
+
+	// Halfway through the next window, half of the previous count still applies
+	now = now.Add(90 * time.Second)
+	if res, _ := l.AllowN(ctx, "user:1", 2); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected allowed with 0 remaining, got %+v", res)
+	}
+	res, _ := l.Allow(ctx, "user:1")
+	if res.Allowed || res.RetryAfter != 30*time.Second {
+		t.Errorf("expected denial for 30s, got %+v", res)
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := NewTokenBucketLimiter(NewMemory(WithCleanupInterval(0)), 3, time.Second)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	if res, _ := l.AllowN(ctx, "user:1", 3); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected a burst of 3, got %+v", res)
+	}
+	res, _ := l.Allow(ctx, "user:1")
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("expected denial for 1s, got %+v", res)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if res, _ := l.Allow(ctx, "user:1"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected one refilled token, got %+v", res)
+	}
+	if res, _ := l.Allow(ctx, "user:1"); res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected denial until the next token, got %+v", res)
+	}
+
+	// The bucket never holds more than its capacity
+	now = now.Add(time.Hour)
+	if res, _ := l.Allow(ctx, "user:1"); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("expected a full bucket, got %+v", res)
+	}
+}
+
+func TestRateLimiter_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte)}
+
+	limiters := map[string]RateLimiter{
+		"FixedWindow":   NewFixedWindowLimiter(m, 1, time.Minute),
+		"SlidingWindow": NewSlidingWindowLimiter(m, 1, time.Minute),
+		"TokenBucket":   NewTokenBucketLimiter(m, 1, time.Second),
+	}
+	for name, l := range limiters {
+		if _, err := l.Allow(ctx, "key"); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("%s: expected ErrUnsupported, got %v", name, err)
+		}
+	}
+}
+
+func TestRateLimiter_SubMillisecondWindow(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	for name, allow := range map[string]func() (RateLimitResult, error){
+		"fixed": func() (RateLimitResult, error) {
+			return NewFixedWindowLimiter(m, 1, time.Microsecond).Allow(ctx, "fixed")
+		},
+		"sliding": func() (RateLimitResult, error) {
+			return NewSlidingWindowLimiter(m, 1, time.Microsecond).Allow(ctx, "sliding")
+		},
+	} {
+		if res, err := allow(); err != nil || !res.Allowed {
+			t.Errorf("%s: expected the first request to be allowed, got %+v (%v)", name, res, err)
+		}
+	}
+}
@@ -2,15 +2,25 @@ package cache
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"iter"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+// redisInvalidateChannel is where Redis publishes invalidations to RESP2
+// clients that redirect tracking to a subscribed connection.
+const redisInvalidateChannel = "__redis__:invalidate"
+
 type RedisCache struct {
-	client redis.Cmdable
+	client redis.UniversalClient
+	codec  Codec
+
+	invalidations invalidationHandlers
+	tracker       *redis.PubSub
 }
 
 func NewRedis(opts ...Option) (*RedisCache, error) {
@@ -19,10 +29,14 @@ func NewRedis(opts ...Option) (*RedisCache, error) {
 		opt(cfg)
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.Database,
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:         cfg.addrs(),
+		MasterName:    cfg.MasterName,
+		IsClusterMode: cfg.Cluster,
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		DB:            cfg.Database,
+		TLSConfig:     cfg.TLSConfig,
 	})
 
 	if err := redisotel.InstrumentTracing(client); err != nil {
@@ -33,7 +47,79 @@ func NewRedis(opts ...Option) (*RedisCache, error) {
 		return nil, err
 	}
 
-	return &RedisCache{client: client}, nil
+	r, err := newRedis(client, cfg)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// NewRedisFromClient returns a RedisCache over an existing client, so its
+// connection pool can be shared with other libraries. The client is used as
+// it is: instrument it with redisotel beforehand for traces and metrics.
+// Connection options are ignored, and Close closes the client.
+func NewRedisFromClient(client redis.UniversalClient, opts ...Option) (*RedisCache, error) {
+	cfg := &Config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return newRedis(client, cfg)
+}
+
+func newRedis(client redis.UniversalClient, cfg *Config) (*RedisCache, error) {
+	// Tracking subscribes a single connection, which cannot see every shard.
+	single, ok := client.(*redis.Client)
+	if cfg.Tracking && !ok {
+		return nil, errors.New("cache: WithTracking is not supported on Redis Cluster")
+	}
+
+	if cfg.PingTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.PingTimeout)
+		defer cancel()
+
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &RedisCache{client: client, codec: cfg.Codec}
+	if cfg.Tracking {
+		r.track(*single.Options())
+	}
+
+	return r, nil
+}
+
+// OnInvalidate registers fn to be called with the keys changed on the server.
+// It is only called when the cache was created with WithTracking.
+func (r *RedisCache) OnInvalidate(fn func(keys []string)) {
+	r.invalidations.add(fn)
+}
+
+// Client returns the instrumented go-redis client, so commands the Cache
+// interface lacks, and other packages such as messaging, share its
+// connection pool.
+func (r *RedisCache) Client() redis.UniversalClient {
+	return r.client
+}
+
+func (r *RedisCache) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Close stops tracking invalidations and closes the client.
+func (r *RedisCache) Close() error {
+	if r.tracker != nil {
+		_ = r.tracker.Close()
+	}
+	return r.client.Close()
+}
+
+func (r *RedisCache) Codec() Codec {
+	return r.codec
 }
 
 func (r *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
@@ -41,9 +127,397 @@ func (r *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time
 }
 
 func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
-	return r.client.Get(ctx, key).Bytes()
+	val, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+
+	return val, err
 }
 
 func (r *RedisCache) Del(ctx context.Context, key string) error {
 	return r.client.Del(ctx, key).Err()
 }
+
+func (r *RedisCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (r *RedisCache) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	data := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return data, nil
+	}
+
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		// MGET fails with CROSSSLOT when the keys hash to different slots,
+		// so pipeline single-key GETs, which the cluster client routes.
+		cmds, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+
+		for i, cmd := range cmds {
+			if val, err := cmd.(*redis.StringCmd).Bytes(); err == nil {
+				data[keys[i]] = val
+			}
+		}
+
+		return data, nil
+	}
+
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, val := range vals {
+		if s, ok := val.(string); ok {
+			data[keys[i]] = []byte(s)
+		}
+	}
+
+	return data, nil
+}
+
+func (r *RedisCache) SetMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range items {
+			pipe.Set(ctx, key, value, ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (r *RedisCache) DelMany(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		// Like GetMany, single-key DELs avoid CROSSSLOT errors.
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+			return nil
+		})
+		return err
+	}
+
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// Scan lists the keys matching pattern with SCAN. On Redis Cluster every
+// master is scanned in turn.
+func (r *RedisCache) Scan(ctx context.Context, pattern string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		nodes, err := r.masters(ctx)
+		if err != nil {
+			yield("", err)
+			return
+		}
+
+		for _, node := range nodes {
+			it := node.Scan(ctx, 0, pattern, scanCount).Iterator()
+			for it.Next(ctx) {
+				if !yield(it.Val(), nil) {
+					return
+				}
+			}
+			if err := it.Err(); err != nil {
+				yield("", err)
+				return
+			}
+		}
+	}
+}
+
+// DelPattern deletes the keys matching pattern in pipelines of single-key
+// DELs, which Redis Cluster routes to the slot of each key.
+func (r *RedisCache) DelPattern(ctx context.Context, pattern string) (int64, error) {
+	return delScanned(r.Scan(ctx, pattern), func(batch []string) (int64, error) {
+		cmds, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range batch {
+				pipe.Del(ctx, key)
+			}
+			return nil
+		})
+
+		var deleted int64
+		for _, cmd := range cmds {
+			deleted += cmd.(*redis.IntCmd).Val()
+		}
+
+		return deleted, err
+	})
+}
+
+// masters returns the clients to scan: every master of a cluster, or the
+// client itself.
+func (r *RedisCache) masters(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{r.client}, nil
+	}
+
+	var mu sync.Mutex
+	var nodes []redis.Cmdable
+	err := cluster.ForEachMaster(ctx, func(_ context.Context, node *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		nodes = append(nodes, node)
+		return nil
+	})
+
+	return nodes, err
+}
+
+// track subscribes a dedicated RESP2 connection to the invalidations of every
+// key, redirecting its own tracking to itself. The subscription is restored by
+// go-redis after a reconnect, which re-runs OnConnect.
+func (r *RedisCache) track(opt redis.Options) {
+	opt.Protocol = 2
+	opt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		// Invalidations sent while disconnected were lost.
+		r.invalidations.notify(nil)
+
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+
+		return cn.Do(ctx, "CLIENT", "TRACKING", "ON", "REDIRECT", id, "BCAST").Err()
+	}
+
+	ctx := context.Background()
+	r.tracker = redis.NewClient(&opt).Subscribe(ctx, redisInvalidateChannel)
+
+	go func() {
+		for {
+			msg, err := r.tracker.Receive(ctx)
+			if errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			if err != nil {
+				// A flush is published with a nil payload go-redis cannot
+				// decode, and a broken connection may have lost invalidations.
+				r.invalidations.notify(nil)
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			if m, ok := msg.(*redis.Message); ok {
+				r.invalidations.notify(m.PayloadSlice)
+			}
+		}
+	}()
+}
+
+func (r *RedisCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	res, err := r.runScript(ctx, incrScript, []string{key}, delta, ttl.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res[0], nil
+}
+
+func (r *RedisCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return r.Incr(ctx, key, -delta, ttl)
+}
+
+func (r *RedisCache) CompareAndExpire(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	n, err := compareAndExpireScript.redis.Run(ctx, r.client, []string{key}, value, ttl.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+func (r *RedisCache) CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error) {
+	n, err := compareAndDeleteScript.redis.Run(ctx, r.client, []string{key}, value).Int64()
+	return n == 1, err
+}
+
+func (r *RedisCache) runScript(ctx context.Context, s *script, keys []string, args ...int64) ([]int64, error) {
+	argv := make([]any, len(args))
+	for i, arg := range args {
+		argv[i] = arg
+	}
+
+	return s.redis.Run(ctx, r.client, keys, argv...).Int64Slice()
+}
+
+func (r *RedisCache) HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) error {
+	values := make([]any, 0, 2*len(fields))
+	for field, value := range fields {
+		values = append(values, field, value)
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, values...)
+		if ttl > 0 {
+			pipe.PExpire(ctx, key, ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (r *RedisCache) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	val, err := r.client.HGet(ctx, key, field).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+
+	return val, err
+}
+
+func (r *RedisCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	values, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string][]byte, len(values))
+	for field, value := range values {
+		fields[field] = []byte(value)
+	}
+
+	return fields, nil
+}
+
+func (r *RedisCache) HDel(ctx context.Context, key string, fields ...string) error {
+	return r.client.HDel(ctx, key, fields...).Err()
+}
+
+func (r *RedisCache) SAdd(ctx context.Context, key string, members [][]byte, ttl time.Duration) (int64, error) {
+	var added *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.SAdd(ctx, key, anySlice(members)...)
+		if ttl > 0 {
+			pipe.PExpire(ctx, key, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return added.Val(), nil
+}
+
+func (r *RedisCache) SRem(ctx context.Context, key string, members [][]byte) (int64, error) {
+	return r.client.SRem(ctx, key, anySlice(members)...).Result()
+}
+
+func (r *RedisCache) SMembers(ctx context.Context, key string) ([][]byte, error) {
+	values, err := r.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return byteSlices(values), nil
+}
+
+func (r *RedisCache) SIsMember(ctx context.Context, key string, member []byte) (bool, error) {
+	return r.client.SIsMember(ctx, key, member).Result()
+}
+
+func (r *RedisCache) ZAdd(ctx context.Context, key string, members []Scored[[]byte], ttl time.Duration) (int64, error) {
+	zs := make([]redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = redis.Z{Score: m.Score, Member: m.Member}
+	}
+
+	var added *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.ZAdd(ctx, key, zs...)
+		if ttl > 0 {
+			pipe.PExpire(ctx, key, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return added.Val(), nil
+}
+
+func (r *RedisCache) ZIncrBy(ctx context.Context, key string, member []byte, delta float64) (float64, error) {
+	return r.client.ZIncrBy(ctx, key, delta, string(member)).Result()
+}
+
+func (r *RedisCache) ZScore(ctx context.Context, key string, member []byte) (float64, error) {
+	score, err := r.client.ZScore(ctx, key, string(member)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotFound
+	}
+
+	return score, err
+}
+
+func (r *RedisCache) ZRem(ctx context.Context, key string, members [][]byte) (int64, error) {
+	return r.client.ZRem(ctx, key, anySlice(members)...).Result()
+}
+
+func (r *RedisCache) ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64, rev bool) ([]Scored[[]byte], error) {
+	if offset > 0 && count == 0 {
+		count = -1
+	}
+
+	return r.zrange(ctx, redis.ZRangeArgs{
+		Key:     key,
+		Start:   formatScore(min),
+		Stop:    formatScore(max),
+		ByScore: true,
+		Rev:     rev,
+		Offset:  offset,
+		Count:   count,
+	})
+}
+
+func (r *RedisCache) ZRangeByRank(ctx context.Context, key string, start, stop int64, rev bool) ([]Scored[[]byte], error) {
+	return r.zrange(ctx, redis.ZRangeArgs{Key: key, Start: start, Stop: stop, Rev: rev})
+}
+
+func (r *RedisCache) zrange(ctx context.Context, args redis.ZRangeArgs) ([]Scored[[]byte], error) {
+	zs, err := r.client.ZRangeArgsWithScores(ctx, args).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]Scored[[]byte], len(zs))
+	for i, z := range zs {
+		member, _ := z.Member.(string)
+		members[i] = Scored[[]byte]{Member: []byte(member), Score: z.Score}
+	}
+
+	return members, nil
+}
+
+func anySlice(values [][]byte) []any {
+	args := make([]any, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+
+	return args
+}
+
+func byteSlices(values []string) [][]byte {
+	out := make([][]byte, len(values))
+	for i, value := range values {
+		out[i] = []byte(value)
+	}
+
+	return out
+}
@@ -1,8 +1,16 @@
 package cache
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,10 +18,18 @@ import (
 )
 
 type mockRedis struct {
-	redis.Cmdable
-	resSet *redis.StatusCmd
-	resGet *redis.StringCmd
-	resDel *redis.IntCmd
+	redis.UniversalClient
+	resSet  *redis.StatusCmd
+	resGet  *redis.StringCmd
+	resDel  *redis.IntCmd
+	resNX   *redis.BoolCmd
+	resMGet *redis.SliceCmd
+	resEval *redis.Cmd
+	errPipe error
+}
+
+func (m *mockRedis) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return m.resEval
 }
 
 func (m *mockRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
@@ -24,6 +40,18 @@ func (m *mockRedis) Get(ctx context.Context, key string) *redis.StringCmd {
 	return m.resGet
 }
 
+func (m *mockRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	return m.resNX
+}
+
+func (m *mockRedis) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	return m.resMGet
+}
+
+func (m *mockRedis) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return nil, m.errPipe
+}
+
 func (m *mockRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
 	return m.resDel
 }
@@ -83,6 +111,105 @@ func TestRedis(t *testing.T) {
 			t.Errorf("expected %v, got %v", wantErr, err)
 		}
 	})
+
+	t.Run("Add", func(t *testing.T) {
+		m := &mockRedis{resNX: redis.NewBoolCmd(ctx)}
+		m.resNX.SetVal(true)
+
+		r := &RedisCache{client: m}
+
+		ok, err := r.Add(ctx, "key", []byte("value"), time.Minute)
+		if err != nil {
+			t.Errorf("Add failed: %v", err)
+		}
+		if !ok {
+			t.Error("expected value to be added")
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		m := &mockRedis{
+			resMGet: redis.NewSliceCmd(ctx),
+			resDel:  redis.NewIntCmd(ctx),
+		}
+		m.resMGet.SetVal([]interface{}{"1", nil, "3"})
+
+		r := &RedisCache{client: m}
+
+		data, err := r.GetMany(ctx, []string{"a", "b", "c"})
+		if err != nil {
+			t.Fatalf("GetMany failed: %v", err)
+		}
+		if len(data) != 2 || string(data["a"]) != "1" || string(data["c"]) != "3" {
+			t.Errorf("unexpected hits %v", data)
+		}
+
+		if data, err := r.GetMany(ctx, nil); err != nil || len(data) != 0 {
+			t.Errorf("expected no hits for no keys, got %v, %v", data, err)
+		}
+
+		if err := r.SetMany(ctx, map[string][]byte{"a": []byte("1")}, time.Minute); err != nil {
+			t.Errorf("SetMany failed: %v", err)
+		}
+
+		if err := r.DelMany(ctx, []string{"a", "b"}); err != nil {
+			t.Errorf("DelMany failed: %v", err)
+		}
+		if err := r.DelMany(ctx, nil); err != nil {
+			t.Errorf("DelMany failed: %v", err)
+		}
+
+		wantErr := errors.New("redis error")
+		m.resMGet.SetErr(wantErr)
+		if _, err := r.GetMany(ctx, []string{"a"}); err != wantErr {
+			t.Errorf("expected %v, got %v", wantErr, err)
+		}
+	})
+
+	t.Run("Miss", func(t *testing.T) {
+		m := &mockRedis{resGet: redis.NewStringCmd(ctx)}
+		m.resGet.SetErr(redis.Nil)
+
+		r := &RedisCache{client: m}
+
+		if _, err := r.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %v, got %v", ErrNotFound, err)
+		}
+	})
+}
+
+func TestRedis_Counter(t *testing.T) {
+	ctx := context.Background()
+	m := &mockRedis{resEval: redis.NewCmd(ctx)}
+	m.resEval.SetVal([]interface{}{int64(3)})
+	r := &RedisCache{client: m}
+
+	if n, err := r.Incr(ctx, "hits", 1, time.Minute); err != nil || n != 3 {
+		t.Errorf("expected 3, got %d, %v", n, err)
+	}
+	if n, err := r.Decr(ctx, "hits", 1, time.Minute); err != nil || n != 3 {
+		t.Errorf("expected 3, got %d, %v", n, err)
+	}
+
+	m.resEval.SetVal([]interface{}{int64(1), int64(4), int64(0)})
+	res, err := NewTokenBucketLimiter(r, 5, time.Second).Allow(ctx, "user:1")
+	if err != nil || !res.Allowed || res.Remaining != 4 {
+		t.Errorf("expected allowed with 4 remaining, got %+v, %v", res, err)
+	}
+
+	m.resEval.SetVal(int64(1))
+	if ok, err := r.CompareAndExpire(ctx, "lock", []byte("token"), time.Minute); !ok || err != nil {
+		t.Errorf("expected CompareAndExpire to succeed, got %v, %v", ok, err)
+	}
+	m.resEval.SetVal(int64(0))
+	if ok, err := r.CompareAndDelete(ctx, "lock", []byte("token")); ok || err != nil {
+		t.Errorf("expected CompareAndDelete to fail on another token, got %v, %v", ok, err)
+	}
+
+	m.resEval.SetErr(errors.New("redis error"))
+	if _, err := r.Incr(ctx, "hits", 1, time.Minute); err == nil {
+		t.Error("expected error")
+	}
 }
 
 func TestNewRedis(t *testing.T) {
@@ -101,3 +228,265 @@ func TestNewRedis(t *testing.T) {
 		t.Fatal("expected RedisCache instance")
 	}
 }
+
+func TestNewRedis_Tracking(t *testing.T) {
+	r, err := NewRedis(
+		WithHost("127.0.0.1"),
+		WithPort(1),
+		WithTracking(),
+	)
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+
+	// Without a server every receive fails, which must flush near caches
+	flushed := make(chan struct{}, 1)
+	r.OnInvalidate(func(keys []string) {
+		if keys == nil {
+			select {
+			case flushed <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Error("expected a flush while disconnected")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestNewRedis_Modes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want any
+	}{
+		{"Single", []Option{WithHost("127.0.0.1"), WithPort(1)}, &redis.Client{}},
+		{"Cluster", []Option{WithAddrs("127.0.0.1:1", "127.0.0.1:2")}, &redis.ClusterClient{}},
+		{"ClusterSingleAddr", []Option{WithAddrs("127.0.0.1:1"), WithCluster()}, &redis.ClusterClient{}},
+		{"Sentinel", []Option{WithAddrs("127.0.0.1:1", "127.0.0.1:2"), WithSentinel("mymaster")}, &redis.Client{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append(tt.opts, WithUsername("app"), WithTLS(&tls.Config{ServerName: "redis"}))
+			r, err := NewRedis(opts...)
+			if err != nil {
+				t.Fatalf("NewRedis failed: %v", err)
+			}
+			defer r.Close()
+
+			if fmt.Sprintf("%T", r.client) != fmt.Sprintf("%T", tt.want) {
+				t.Errorf("expected %T, got %T", tt.want, r.client)
+			}
+		})
+	}
+}
+
+func TestNewRedis_ClusterTracking(t *testing.T) {
+	if _, err := NewRedis(WithAddrs("127.0.0.1:1", "127.0.0.1:2"), WithTracking()); err == nil {
+		t.Error("expected an error for tracking on Redis Cluster")
+	}
+}
+
+func TestNewRedis_Ping(t *testing.T) {
+	if _, err := NewRedis(WithHost("127.0.0.1"), WithPort(1), WithPing(time.Second)); err == nil {
+		t.Error("expected NewRedis to fail fast without a server")
+	}
+
+	r, _ := NewRedis(WithHost("127.0.0.1"), WithPort(1))
+	defer r.Close()
+	if err := Ping(context.Background(), r); err == nil {
+		t.Error("expected Ping to fail without a server")
+	}
+}
+
+func TestNewRedisFromClient(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+
+	r, err := NewRedisFromClient(client, WithCodec(MsgPack))
+	if err != nil {
+		t.Fatalf("NewRedisFromClient failed: %v", err)
+	}
+	if r.Client() != client || r.Codec() != MsgPack {
+		t.Error("expected the cache to use the given client and codec")
+	}
+
+	if _, err := NewRedisFromClient(client, WithPing(time.Second)); err == nil {
+		t.Error("expected the ping to fail without a server")
+	}
+	if _, err := NewRedisFromClient(redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:1"}}), WithTracking()); err == nil {
+		t.Error("expected an error for tracking on Redis Cluster")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	r, _ := NewRedis(WithHost("127.0.0.1"), WithPort(1))
+	defer r.Close()
+	c := NewBreaker(NewNamespaced(NewTiered(r, WithCleanupInterval(0)), WithNamespace("app")))
+	defer c.Close()
+
+	if client, ok := Unwrap[redis.UniversalClient](c); !ok || client != r.Client() {
+		t.Error("expected the redis client through the wrappers")
+	}
+	if backend, ok := Unwrap[*RedisCache](c); !ok || backend != r {
+		t.Error("expected the redis backend through the wrappers")
+	}
+	if tiered, ok := Unwrap[*TieredCache](c); !ok || tiered == nil {
+		t.Error("expected the tiered wrapper")
+	}
+	if _, ok := Unwrap[*MemoryCache](c); ok {
+		t.Error("expected no memory cache, the L1 of a tiered cache is not unwrapped")
+	}
+	if _, ok := Unwrap[*RedisCache](nil); ok {
+		t.Error("expected nothing from a nil cache")
+	}
+}
+
+// fakeClusterNode serves a single slot range over RESP2 and, like a real
+// cluster node, rejects commands with several keys since they may hash to
+// different slots.
+type fakeClusterNode struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func newFakeClusterNode(t *testing.T) *fakeClusterNode {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	n := &fakeClusterNode{ln: ln, data: make(map[string]string)}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go n.serve(conn)
+		}
+	}()
+
+	return n
+}
+
+func (n *fakeClusterNode) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte(n.handle(args)))
+	}
+}
+
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func (n *fakeClusterNode) handle(args []string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	cmd := strings.ToUpper(args[0])
+	if (cmd == "MGET" || cmd == "DEL") && len(args) > 2 {
+		return "-CROSSSLOT Keys in request don't hash to the same slot\r\n"
+	}
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		n.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		val, ok := n.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(val), val)
+	case "DEL":
+		_, ok := n.data[args[1]]
+		delete(n.data, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedis_ClusterMultiKey(t *testing.T) {
+	ctx := context.Background()
+	node := newFakeClusterNode(t)
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Protocol:        2,
+		DisableIdentity: true,
+		ClusterSlots: func(context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{{Start: 0, End: 16383, Nodes: []redis.ClusterNode{{Addr: node.ln.Addr().String()}}}}, nil
+		},
+	})
+	r, err := NewRedisFromClient(client)
+	if err != nil {
+		t.Fatalf("NewRedisFromClient failed: %v", err)
+	}
+	defer r.Close()
+
+	// "a" and "b" hash to different slots.
+	_ = r.Set(ctx, "a", []byte("1"), 0)
+	_ = r.Set(ctx, "b", []byte("2"), 0)
+
+	data, err := r.GetMany(ctx, []string{"a", "b", "missing"})
+	if err != nil || len(data) != 2 || string(data["a"]) != "1" || string(data["b"]) != "2" {
+		t.Fatalf("expected both keys, got %v (%v)", data, err)
+	}
+
+	if err := r.DelMany(ctx, []string{"a", "b"}); err != nil {
+		t.Fatalf("DelMany failed: %v", err)
+	}
+	if data, _ := r.GetMany(ctx, []string{"a", "b"}); len(data) != 0 {
+		t.Errorf("expected both keys deleted, got %v", data)
+	}
+}
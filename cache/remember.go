@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// flights coalesces concurrent misses for the same key of the same cache
+// within this process. Its keys come from flightKey.
+var flights singleflight.Group
+
+// flightKey scopes a flight of kind to the cache c, since flights is shared
+// by every cache in the process. The cache is identified by its address,
+// which cannot be reused while a flight holds it. ok is false for caches
+// that are not pointers, which then have no single flight.
+func flightKey(c Cache, kind, key string) (string, bool) {
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Pointer {
+		return "", false
+	}
+
+	// kind and the address never contain a NUL, so no key can make two
+	// flights collide.
+	return kind + "\x00" + strconv.FormatUint(uint64(v.Pointer()), 16) + "\x00" + key, true
+}
+
+func Remember[T any](ctx context.Context, c Cache, key string, ttl time.Duration, fn func() (T, error), opts ...CallOption) (T, error) {
+	var zero T
+
+	cfg := newCallConfig(opts)
+
+	if result, err := lookup[T](ctx, c, key, "remember", cfg); !missed(err) {
+		return result, err
+	}
+
+	flight, ok := flightKey(c, "remember", key)
+	if !cfg.singleFlight || !ok {
+		return load(ctx, c, key, ttl, fn, cfg)
+	}
+
+	// The load is shared by every caller, so it must outlive the cancellation
+	// of the one that started it; each caller stops waiting on its own ctx.
+	ch := flights.DoChan(flight, func() (any, error) {
+		return load(context.WithoutCancel(ctx), c, key, ttl, fn, cfg)
+	})
+
+	var res singleflight.Result
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res = <-ch:
+	}
+	if res.Err != nil {
+		return zero, res.Err
+	}
+
+	result, ok := res.Val.(T)
+	if !ok {
+		// The same key was remembered concurrently with a different type.
+		return load(ctx, c, key, ttl, fn, cfg)
+	}
+
+	return result, nil
+}
+
+// WithSingleFlight makes concurrent misses for the same key of the same cache
+// in this process share a single call to fn.
+func WithSingleFlight() CallOption {
+	return func(c *callConfig) {
+		c.singleFlight = true
+	}
+}
+
+// WithLock makes replicas take a distributed lock before calling fn, so only
+// one of them recomputes an expired key while the others wait for the result.
+// The lock expires after ttl in case its holder dies. It requires a backend
+// that implements Adder and is ignored otherwise.
+func WithLock(ttl time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.lockTTL = ttl
+	}
+}
+
+// WithLockRetry sets how often a replica waiting on WithLock polls for the
+// result (default: 50ms).
+func WithLockRetry(interval time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.lockRetry = interval
+	}
+}
+
+// WithStale keeps a copy of the value for ttl+staleTTL, which replicas that
+// lose the WithLock race serve instead of waiting.
+func WithStale(staleTTL time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.staleTTL = staleTTL
+	}
+}
+
+func load[T any](ctx context.Context, c Cache, key string, ttl time.Duration, fn func() (T, error), cfg *callConfig) (T, error) {
+	if a, ok := c.(Adder); ok && cfg.lockTTL > 0 {
+		return loadLocked(ctx, c, a, key, ttl, fn, cfg)
+	}
+
+	return compute(ctx, c, key, ttl, fn, cfg)
+}
+
+func loadLocked[T any](ctx context.Context, c Cache, a Adder, key string, ttl time.Duration, fn func() (T, error), cfg *callConfig) (T, error) {
+	var zero T
+
+	lock := lockKey(key)
+	token := lockToken()
+
+	for {
+		acquired, err := a.Add(ctx, lock, token, cfg.lockTTL)
+		if errors.Is(err, ErrUnavailable) || errors.Is(err, errors.ErrUnsupported) {
+			// A wrapper over a backend without Add, or a cache that is down:
+			// nobody can hold the lock.
+			return compute(ctx, c, key, ttl, fn, cfg)
+		}
+		if err != nil {
+			return zero, err
+		}
+
+		if acquired {
+			// Another replica may have filled the key between our miss and the lock.
+			result, err := get[T](ctx, c, key, codecFor(c, cfg))
+			if missed(err) {
+				result, err = compute(ctx, c, key, ttl, fn, cfg)
+			}
+
+			releaseLock(context.WithoutCancel(ctx), c, lock, token)
+			return result, err
+		}
+
+		if cfg.staleTTL > 0 {
+			if result, err := get[T](ctx, c, staleKey(key), codecFor(c, cfg)); !missed(err) {
+				return result, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-time.After(cfg.lockRetry):
+		}
+
+		if result, err := get[T](ctx, c, key, codecFor(c, cfg)); !missed(err) {
+			return result, err
+		}
+	}
+}
+
+func compute[T any](ctx context.Context, c Cache, key string, ttl time.Duration, fn func() (T, error), cfg *callConfig) (T, error) {
+	var zero T
+
+	start := time.Now()
+	result, err := fn()
+	recordCompute(ctx, c, key, cfg, time.Since(start))
+	if err != nil {
+		return zero, err
+	}
+
+	bytes, err := codecFor(c, cfg).Marshal(result)
+	if err == nil {
+		err = set(ctx, c, key, bytes, ttl, cfg)
+	}
+	recordWrite(ctx, c, key, "remember", cfg, len(bytes), err)
+	if err != nil {
+		return failOpen(result, err)
+	}
+
+	if cfg.staleTTL > 0 {
+		if err := set(ctx, c, staleKey(key), bytes, ttl+cfg.staleTTL, cfg); err != nil {
+			return failOpen(result, err)
+		}
+	}
+
+	return result, nil
+}
+
+// missed reports whether a lookup should fall through to fn: the key is
+// missing or the cache could not be read.
+func missed(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnavailable)
+}
+
+// failOpen returns result when err only means the cache is unavailable.
+func failOpen[T any](result T, err error) (T, error) {
+	if errors.Is(err, ErrUnavailable) {
+		return result, nil
+	}
+
+	var zero T
+	return zero, err
+}
+
+func staleKey(key string) string {
+	return key + ":stale"
+}
+
+func lockKey(key string) string {
+	return key + ":lock"
+}
+
+func lockToken() []byte {
+	return []byte(rand.Text())
+}
@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemember_SingleFlight(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "db", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan string, 10)
+	for range 10 {
+		wg.Go(func() {
+			got, err := Remember(ctx, m, "hot", time.Minute, fn, WithSingleFlight())
+			if err != nil {
+				t.Errorf("Remember failed: %v", err)
+			}
+			results <- got
+		})
+	}
+
+	// Let every caller miss before the computation finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 call, got %d", n)
+	}
+	for got := range results {
+		if got != "db" {
+			t.Errorf("expected db, got %s", got)
+		}
+	}
+}
+
+func TestRemember_SingleFlightCancel(t *testing.T) {
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	release := make(chan struct{})
+	fn := func() (string, error) {
+		<-release
+		return "db", nil
+	}
+
+	// The caller starting the flight gives up while it runs.
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := Remember(leaderCtx, m, "hot", time.Minute, fn, WithSingleFlight())
+		leader <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	follower := make(chan string, 1)
+	go func() {
+		got, err := Remember(context.Background(), m, "hot", time.Minute, fn, WithSingleFlight())
+		if err != nil {
+			t.Errorf("expected the follower to get the value, got %v", err)
+		}
+		follower <- got
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-leader:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the cancelled caller to stop waiting")
+	}
+
+	close(release)
+	if got := <-follower; got != "db" {
+		t.Errorf("expected db, got %s", got)
+	}
+	if got, err := Get[string](context.Background(), m, "hot"); err != nil || got != "db" {
+		t.Errorf("expected the shared load to store db, got %q (%v)", got, err)
+	}
+}
+
+func TestRemember_SingleFlightError(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	fnErr := errors.New("fn error")
+	_, err := Remember(ctx, m, "key", time.Minute, func() (int, error) {
+		return 0, fnErr
+	}, WithSingleFlight())
+	if !errors.Is(err, fnErr) {
+		t.Fatalf("expected %v, got %v", fnErr, err)
+	}
+}
+
+func TestRemember_Lock(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	// Another replica holds the lock and fills the key while we wait
+	_, _ = m.Add(ctx, "key:lock", []byte("other"), time.Minute)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		_ = Set(ctx, m, "key", "replica", time.Minute)
+	}()
+
+	called := 0
+	got, err := Remember(ctx, m, "key", time.Minute, func() (string, error) {
+		called++
+		return "local", nil
+	}, WithLock(time.Second), WithLockRetry(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+	if got != "replica" {
+		t.Errorf("expected replica, got %s", got)
+	}
+	if called != 0 {
+		t.Errorf("expected fn not to be called while lock is held, got %d calls", called)
+	}
+}
+
+func TestRemember_LockAcquired(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	got, err := Remember(ctx, m, "key", time.Minute, func() (string, error) {
+		return "local", nil
+	}, WithLock(time.Second))
+	if err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+	if got != "local" {
+		t.Errorf("expected local, got %s", got)
+	}
+
+	// The lock is released once the value is stored
+	if _, err := m.Get(ctx, "key:lock"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected lock to be released, got %v", err)
+	}
+}
+
+func TestRemember_LockExpired(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	// The holder died; its lock expires and we take over
+	_, _ = m.Add(ctx, "key:lock", []byte("dead"), 30*time.Millisecond)
+
+	got, err := Remember(ctx, m, "key", time.Minute, func() (string, error) {
+		return "local", nil
+	}, WithLock(time.Second), WithLockRetry(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+	if got != "local" {
+		t.Errorf("expected local, got %s", got)
+	}
+}
+
+func TestRemember_LockContextCanceled(t *testing.T) {
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	_, _ = m.Add(ctx, "key:lock", []byte("other"), time.Minute)
+
+	_, err := Remember(ctx, m, "key", time.Minute, func() (string, error) {
+		return "local", nil
+	}, WithLock(time.Second), WithLockRetry(10*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestRemember_Stale(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	opts := []CallOption{WithLock(time.Second), WithStale(time.Minute)}
+
+	_, err := Remember(ctx, m, "key", time.Second, func() (string, error) {
+		return "v1", nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+
+	// The hard TTL lapses while another replica recomputes
+	now = now.Add(2 * time.Second)
+	_, _ = m.Add(ctx, "key:lock", []byte("other"), time.Minute)
+
+	got, err := Remember(ctx, m, "key", time.Second, func() (string, error) {
+		return "v2", nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+	if got != "v1" {
+		t.Errorf("expected stale v1, got %s", got)
+	}
+}
+
+func TestRemember_LockWithoutAdder(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte)}
+
+	got, err := Remember(ctx, m, "key", time.Minute, func() (string, error) {
+		return "local", nil
+	}, WithLock(time.Second))
+	if err != nil {
+		t.Fatalf("Remember failed: %v", err)
+	}
+	if got != "local" {
+		t.Errorf("expected local, got %s", got)
+	}
+}
+
+func TestRemember_LockAddError(t *testing.T) {
+	ctx := context.Background()
+	addErr := errors.New("add error")
+	a := &addErrCache{mockCache: mockCache{data: make(map[string][]byte)}, addErr: addErr}
+
+	_, err := Remember(ctx, a, "key", time.Minute, func() (string, error) {
+		return "local", nil
+	}, WithLock(time.Second))
+	if !errors.Is(err, addErr) {
+		t.Fatalf("expected %v, got %v", addErr, err)
+	}
+}
+
+type addErrCache struct {
+	mockCache
+	addErr error
+}
+
+func (a *addErrCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return false, a.addErr
+}
+
+func TestRemember_SingleFlightPerCache(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+	tenantA := NewNamespaced(m, WithNamespace("a"))
+	tenantB := NewNamespaced(m, WithNamespace("b"))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan string)
+	go func() {
+		got, _ := Remember(ctx, tenantA, "profile", time.Minute, func() (string, error) {
+			close(started)
+			<-release
+			return "a", nil
+		}, WithSingleFlight())
+		done <- got
+	}()
+	<-started
+
+	// The same key loaded for another tenant must not join the flight of a.
+	other := make(chan string)
+	go func() {
+		got, _ := Remember(ctx, tenantB, "profile", time.Minute, func() (string, error) {
+			return "b", nil
+		}, WithSingleFlight())
+		other <- got
+	}()
+	select {
+	case got := <-other:
+		if got != "b" {
+			t.Errorf("expected b, got %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected b not to wait for the flight of a")
+		close(release)
+		<-other
+		<-done
+		return
+	}
+
+	close(release)
+	if got := <-done; got != "a" {
+		t.Errorf("expected a, got %s", got)
+	}
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// Scanner is implemented by backends that can enumerate their keys. Patterns
+// use the glob syntax of the Redis SCAN command: * and ? match any run of
+// characters and any single character, [abc], [^a] and [a-z] match sets, and
+// \ escapes the next character.
+//
+// Keys are listed incrementally, so Scan never blocks the server like KEYS
+// does, but keys written or deleted during the scan may or may not be listed,
+// and a key may be listed more than once.
+type Scanner interface {
+	Scan(ctx context.Context, pattern string) iter.Seq2[string, error]
+	DelPattern(ctx context.Context, pattern string) (int64, error)
+}
+
+// scanCount is the number of keys asked per SCAN call and deleted per batch.
+const scanCount = 100
+
+// Scan lists the keys matching pattern. The sequence stops at the first error.
+func Scan(ctx context.Context, c Cache, pattern string) iter.Seq2[string, error] {
+	s, ok := c.(Scanner)
+	if !ok {
+		return func(yield func(string, error) bool) {
+			yield("", fmt.Errorf("cache: %T cannot list keys: %w", c, errors.ErrUnsupported))
+		}
+	}
+
+	return s.Scan(ctx, pattern)
+}
+
+// DelPattern deletes the keys matching pattern and returns how many it deleted.
+func DelPattern(ctx context.Context, c Cache, pattern string) (int64, error) {
+	s, ok := c.(Scanner)
+	if !ok {
+		return 0, fmt.Errorf("cache: %T cannot list keys: %w", c, errors.ErrUnsupported)
+	}
+
+	return s.DelPattern(ctx, pattern)
+}
+
+// delScanned passes the scanned keys to del in batches of scanCount and adds
+// up the number of keys it deleted.
+func delScanned(keys iter.Seq2[string, error], del func(batch []string) (int64, error)) (int64, error) {
+	var deleted int64
+	batch := make([]string, 0, scanCount)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := del(batch)
+		deleted += n
+		batch = batch[:0]
+		return err
+	}
+
+	for key, err := range keys {
+		if err != nil {
+			return deleted, err
+		}
+
+		batch = append(batch, key)
+		if len(batch) == scanCount {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+
+	return deleted, flush()
+}
+
+// escapePattern quotes the glob metacharacters in s, so it matches itself.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// matchPattern reports whether key matches the glob pattern, with the
+// semantics of Redis stringmatchlen.
+func matchPattern(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchPattern(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			rest, ok := matchClass(pattern[1:], key[0])
+			if !ok {
+				return false
+			}
+			pattern, key = rest, key[1:]
+			continue
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			key = key[1:]
+		}
+
+		pattern = pattern[1:]
+	}
+
+	return len(key) == 0
+}
+
+// matchClass matches c against the set at the start of pattern, just after
+// its '['. It returns the pattern after the closing ']'.
+func matchClass(pattern string, c byte) (string, bool) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	match := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			match = match || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-':
+			lo, hi := min(pattern[0], pattern[2]), max(pattern[0], pattern[2])
+			match = match || (lo <= c && c <= hi)
+			pattern = pattern[3:]
+		default:
+			match = match || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		// Skip the closing ']'; an unterminated set ends the pattern.
+		pattern = pattern[1:]
+	}
+
+	return pattern, match != negate
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "", true},
+		{"user:*", "user:42", true},
+		{"user:*", "user/42", false},
+		{"user:*:profile", "user:42:profile", true},
+		{"user:*:profile", "user:42:orders", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{"**a", "bba", true},
+		{"a/b*", "a/b/c", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+
+	// Escaped text matches itself only.
+	if !matchPattern(escapePattern("a*[b]?")+"*", "a*[b]?:1") || matchPattern(escapePattern("a*"), "ab") {
+		t.Error("expected escapePattern to quote metacharacters")
+	}
+}
+
+func scanAll(t *testing.T, c Cache, pattern string) []string {
+	t.Helper()
+
+	var keys []string
+	for key, err := range Scan(context.Background(), c, pattern) {
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+func TestScan_Memory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	for _, key := range []string{"user:1", "user:2", "order:1"} {
+		_ = m.Set(ctx, key, []byte("v"), time.Minute)
+	}
+	_ = m.Set(ctx, "user:3", []byte("v"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if got := scanAll(t, m, "user:*"); !slices.Equal(got, []string{"user:1", "user:2"}) {
+		t.Errorf("expected live user keys, got %v", got)
+	}
+
+	n, err := DelPattern(ctx, m, "user:*")
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 deleted, got %d (%v)", n, err)
+	}
+	if got := scanAll(t, m, "*"); !slices.Equal(got, []string{"order:1"}) {
+		t.Errorf("expected order:1 to be left, got %v", got)
+	}
+}
+
+func TestScan_Namespaced(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+	c := NewNamespaced(m, WithNamespace("orders"))
+	other := NewNamespaced(m, WithNamespace("users"))
+
+	_ = Set(ctx, c, "order:1", "a", time.Minute, WithTags("tenant:42"))
+	_ = Set(ctx, c, "order:2", "b", time.Minute)
+	_ = Set(ctx, other, "order:3", "c", time.Minute)
+
+	if got := scanAll(t, c, "*"); !slices.Equal(got, []string{"order:1", "order:2"}) {
+		t.Errorf("expected the keys of the namespace without tags, got %v", got)
+	}
+
+	if _, err := DelPattern(ctx, c, "order:*"); err != nil {
+		t.Fatalf("DelPattern failed: %v", err)
+	}
+	if got := scanAll(t, m, "*"); !slices.Equal(got, []string{"#tags:6:orders:tenant:42", "users:order:3"}) {
+		t.Errorf("expected other namespaces and tags to be left, got %v", got)
+	}
+}
+
+func TestScan_Tiered(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory(WithCleanupInterval(0))
+	defer remote.Close()
+	c := NewTiered(remote, WithCleanupInterval(0))
+	defer c.Close()
+
+	_ = Set(ctx, c, "user:1", "a", time.Minute)
+	if _, err := DelPattern(ctx, c, "user:*"); err != nil {
+		t.Fatalf("DelPattern failed: %v", err)
+	}
+	if _, err := c.Get(ctx, "user:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the L1 copy to be dropped, got %v", err)
+	}
+}
+
+func TestScan_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	m := &mockCache{data: make(map[string][]byte)}
+
+	for _, err := range Scan(ctx, m, "*") {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected ErrUnsupported, got %v", err)
+		}
+	}
+	if _, err := DelPattern(ctx, m, "*"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+
+	b := NewBreaker(m)
+	defer b.Close()
+	if _, err := DelPattern(ctx, b, "*"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported through the breaker, got %v", err)
+	}
+}
@@ -0,0 +1,419 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// HashStore is implemented by backends that store hashes: maps of fields to
+// values under a single key. HGet returns ErrNotFound for a missing field.
+type HashStore interface {
+	HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) error
+	HGet(ctx context.Context, key, field string) ([]byte, error)
+	HGetAll(ctx context.Context, key string) (map[string][]byte, error)
+	HDel(ctx context.Context, key string, fields ...string) error
+}
+
+// SetStore is implemented by backends that store sets of unique members.
+// SAdd and SRem return how many members were added or removed.
+type SetStore interface {
+	SAdd(ctx context.Context, key string, members [][]byte, ttl time.Duration) (int64, error)
+	SRem(ctx context.Context, key string, members [][]byte) (int64, error)
+	SMembers(ctx context.Context, key string) ([][]byte, error)
+	SIsMember(ctx context.Context, key string, member []byte) (bool, error)
+}
+
+// SortedSetStore is implemented by backends that store sets of members
+// ordered by score. ZScore returns ErrNotFound for a missing member. Ranges
+// are in ascending score order unless rev is set; a count of zero means no
+// limit.
+type SortedSetStore interface {
+	ZAdd(ctx context.Context, key string, members []Scored[[]byte], ttl time.Duration) (int64, error)
+	ZIncrBy(ctx context.Context, key string, member []byte, delta float64) (float64, error)
+	ZScore(ctx context.Context, key string, member []byte) (float64, error)
+	ZRem(ctx context.Context, key string, members [][]byte) (int64, error)
+	ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64, rev bool) ([]Scored[[]byte], error)
+	ZRangeByRank(ctx context.Context, key string, start, stop int64, rev bool) ([]Scored[[]byte], error)
+}
+
+// Scored is a member of a sorted set with its score.
+type Scored[T any] struct {
+	Member T
+	Score  float64
+}
+
+func hashStore(c Cache) (HashStore, error) {
+	h, ok := c.(HashStore)
+	if !ok {
+		return nil, fmt.Errorf("cache: %T has no hashes: %w", c, errors.ErrUnsupported)
+	}
+
+	return h, nil
+}
+
+func setStore(c Cache) (SetStore, error) {
+	s, ok := c.(SetStore)
+	if !ok {
+		return nil, fmt.Errorf("cache: %T has no sets: %w", c, errors.ErrUnsupported)
+	}
+
+	return s, nil
+}
+
+func sortedSetStore(c Cache) (SortedSetStore, error) {
+	z, ok := c.(SortedSetStore)
+	if !ok {
+		return nil, fmt.Errorf("cache: %T has no sorted sets: %w", c, errors.ErrUnsupported)
+	}
+
+	return z, nil
+}
+
+// HSet encodes and stores fields in the hash at key. A positive ttl sets the
+// expiry of the whole hash.
+func HSet[T any](ctx context.Context, c Cache, key string, fields map[string]T, ttl time.Duration, opts ...CallOption) error {
+	h, err := hashStore(c)
+	if err != nil {
+		return err
+	}
+
+	codec := codecFor(c, newCallConfig(opts))
+	data := make(map[string][]byte, len(fields))
+	for field, value := range fields {
+		if data[field], err = codec.Marshal(value); err != nil {
+			return err
+		}
+	}
+
+	return h.HSet(ctx, key, data, ttl)
+}
+
+// HGet returns the decoded value of field in the hash at key, or ErrNotFound.
+func HGet[T any](ctx context.Context, c Cache, key, field string, opts ...CallOption) (T, error) {
+	var zero T
+	h, err := hashStore(c)
+	if err != nil {
+		return zero, err
+	}
+
+	data, err := h.HGet(ctx, key, field)
+	if err != nil {
+		return zero, err
+	}
+
+	return decode[T](data, codecFor(c, newCallConfig(opts)))
+}
+
+// HGetAll returns every decoded field of the hash at key, empty when the key
+// does not exist.
+func HGetAll[T any](ctx context.Context, c Cache, key string, opts ...CallOption) (map[string]T, error) {
+	h, err := hashStore(c)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := h.HGetAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	codec := codecFor(c, newCallConfig(opts))
+	fields := make(map[string]T, len(data))
+	for field, raw := range data {
+		if fields[field], err = decode[T](raw, codec); err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
+
+// HDel removes fields from the hash at key.
+func HDel(ctx context.Context, c Cache, key string, fields ...string) error {
+	h, err := hashStore(c)
+	if err != nil {
+		return err
+	}
+
+	return h.HDel(ctx, key, fields...)
+}
+
+// SAdd encodes and adds members to the set at key and returns how many were
+// not members yet. A positive ttl sets the expiry of the whole set.
+func SAdd[T any](ctx context.Context, c Cache, key string, members []T, ttl time.Duration, opts ...CallOption) (int64, error) {
+	s, err := setStore(c)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := encodeMembers(members, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.SAdd(ctx, key, data, ttl)
+}
+
+// SRem removes members from the set at key and returns how many were there.
+func SRem[T any](ctx context.Context, c Cache, key string, members []T, opts ...CallOption) (int64, error) {
+	s, err := setStore(c)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := encodeMembers(members, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.SRem(ctx, key, data)
+}
+
+// SMembers returns the decoded members of the set at key, in no particular
+// order.
+func SMembers[T any](ctx context.Context, c Cache, key string, opts ...CallOption) ([]T, error) {
+	s, err := setStore(c)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.SMembers(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	codec := memberCodecFor(newCallConfig(opts))
+	members := make([]T, len(data))
+	for i, raw := range data {
+		if members[i], err = decode[T](raw, codec); err != nil {
+			return nil, err
+		}
+	}
+
+	return members, nil
+}
+
+// SIsMember reports whether member is in the set at key.
+func SIsMember[T any](ctx context.Context, c Cache, key string, member T, opts ...CallOption) (bool, error) {
+	s, err := setStore(c)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := memberCodecFor(newCallConfig(opts)).Marshal(member)
+	if err != nil {
+		return false, err
+	}
+
+	return s.SIsMember(ctx, key, data)
+}
+
+// ZAdd encodes and adds members to the sorted set at key, updating the score
+// of existing ones, and returns how many were added. A positive ttl sets the
+// expiry of the whole set.
+func ZAdd[T any](ctx context.Context, c Cache, key string, members []Scored[T], ttl time.Duration, opts ...CallOption) (int64, error) {
+	z, err := sortedSetStore(c)
+	if err != nil {
+		return 0, err
+	}
+
+	codec := memberCodecFor(newCallConfig(opts))
+	data := make([]Scored[[]byte], len(members))
+	for i, m := range members {
+		raw, err := codec.Marshal(m.Member)
+		if err != nil {
+			return 0, err
+		}
+		data[i] = Scored[[]byte]{Member: raw, Score: m.Score}
+	}
+
+	return z.ZAdd(ctx, key, data, ttl)
+}
+
+// ZIncrBy adds delta to the score of member in the sorted set at key, adding
+// the member if needed, and returns the new score.
+func ZIncrBy[T any](ctx context.Context, c Cache, key string, member T, delta float64, opts ...CallOption) (float64, error) {
+	z, err := sortedSetStore(c)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := memberCodecFor(newCallConfig(opts)).Marshal(member)
+	if err != nil {
+		return 0, err
+	}
+
+	return z.ZIncrBy(ctx, key, data, delta)
+}
+
+// ZScore returns the score of member in the sorted set at key, or
+// ErrNotFound.
+func ZScore[T any](ctx context.Context, c Cache, key string, member T, opts ...CallOption) (float64, error) {
+	z, err := sortedSetStore(c)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := memberCodecFor(newCallConfig(opts)).Marshal(member)
+	if err != nil {
+		return 0, err
+	}
+
+	return z.ZScore(ctx, key, data)
+}
+
+// ZRem removes members from the sorted set at key and returns how many were
+// there.
+func ZRem[T any](ctx context.Context, c Cache, key string, members []T, opts ...CallOption) (int64, error) {
+	z, err := sortedSetStore(c)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := encodeMembers(members, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return z.ZRem(ctx, key, data)
+}
+
+// ZRangeByScore returns the members of the sorted set at key whose score is
+// between min and max inclusive, lowest first. Use math.Inf for open bounds,
+// WithReverse for highest first and WithLimit to page through them.
+func ZRangeByScore[T any](ctx context.Context, c Cache, key string, min, max float64, opts ...CallOption) ([]Scored[T], error) {
+	z, err := sortedSetStore(c)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := newCallConfig(opts)
+	data, err := z.ZRangeByScore(ctx, key, min, max, cfg.offset, cfg.count, cfg.reverse)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeScored[T](data, memberCodecFor(cfg))
+}
+
+// ZRangeByRank returns the members of the sorted set at key from rank start
+// to rank stop inclusive, lowest score first. Negative ranks count from the
+// end, so 0 and -1 select every member. With WithReverse ranks count from
+// the highest score, as for the top entries of a leaderboard.
+func ZRangeByRank[T any](ctx context.Context, c Cache, key string, start, stop int64, opts ...CallOption) ([]Scored[T], error) {
+	z, err := sortedSetStore(c)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := newCallConfig(opts)
+	data, err := z.ZRangeByRank(ctx, key, start, stop, cfg.reverse)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeScored[T](data, memberCodecFor(cfg))
+}
+
+// Members is the codec of set and sorted set members. Members are compared
+// by their encoding, so it must be deterministic, which gob and msgpack maps
+// are not: strings and byte slices are stored as they are, and other values
+// as JSON, whose maps are sorted by key.
+var Members Codec = memberCodec{}
+
+type memberCodec struct{}
+
+func (memberCodec) Marshal(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+
+	return json.Marshal(v)
+}
+
+func (memberCodec) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case *string:
+		*v = string(data)
+		return nil
+	case *[]byte:
+		*v = bytes.Clone(data)
+		return nil
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// memberCodecFor returns the codec set with WithCallCodec, or Members. The
+// codec of the cache is not used for members.
+func memberCodecFor(cfg *callConfig) Codec {
+	if cfg.codec != nil {
+		return cfg.codec
+	}
+
+	return Members
+}
+
+func encodeMembers[T any](values []T, opts []CallOption) ([][]byte, error) {
+	codec := memberCodecFor(newCallConfig(opts))
+	data := make([][]byte, len(values))
+	for i, value := range values {
+		raw, err := codec.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = raw
+	}
+
+	return data, nil
+}
+
+func decodeScored[T any](data []Scored[[]byte], codec Codec) ([]Scored[T], error) {
+	members := make([]Scored[T], len(data))
+	for i, m := range data {
+		member, err := decode[T](m.Member, codec)
+		if err != nil {
+			return nil, err
+		}
+		members[i] = Scored[T]{Member: member, Score: m.Score}
+	}
+
+	return members, nil
+}
+
+// formatScore formats a score bound, with -inf and +inf for infinities.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "+inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(score, 'f', -1, 64)
+	}
+}
+
+// WithReverse makes ZRangeByScore and ZRangeByRank return the highest scores
+// first.
+func WithReverse() CallOption {
+	return func(c *callConfig) {
+		c.reverse = true
+	}
+}
+
+// WithLimit makes ZRangeByScore skip offset members and return at most count.
+func WithLimit(offset, count int64) CallOption {
+	return func(c *callConfig) {
+		c.offset = offset
+		c.count = count
+	}
+}
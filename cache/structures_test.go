@@ -0,0 +1,379 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// mockStructures keeps hashes, sets and sorted sets in maps.
+type mockStructures struct {
+	mockCache
+	hashes map[string]map[string][]byte
+	sets   map[string]map[string]bool
+	zsets  map[string]map[string]float64
+	ttls   map[string]time.Duration
+}
+
+func newMockStructures() *mockStructures {
+	return &mockStructures{
+		mockCache: mockCache{data: make(map[string][]byte)},
+		hashes:    make(map[string]map[string][]byte),
+		sets:      make(map[string]map[string]bool),
+		zsets:     make(map[string]map[string]float64),
+		ttls:      make(map[string]time.Duration),
+	}
+}
+
+func (m *mockStructures) HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) error {
+	if m.hashes[key] == nil {
+		m.hashes[key] = make(map[string][]byte)
+	}
+	for field, value := range fields {
+		m.hashes[key][field] = value
+	}
+	m.ttls[key] = ttl
+	return nil
+}
+
+func (m *mockStructures) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	value, ok := m.hashes[key][field]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func (m *mockStructures) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	return m.hashes[key], nil
+}
+
+func (m *mockStructures) HDel(ctx context.Context, key string, fields ...string) error {
+	for _, field := range fields {
+		delete(m.hashes[key], field)
+	}
+	return nil
+}
+
+func (m *mockStructures) SAdd(ctx context.Context, key string, members [][]byte, ttl time.Duration) (int64, error) {
+	if m.sets[key] == nil {
+		m.sets[key] = make(map[string]bool)
+	}
+	var n int64
+	for _, member := range members {
+		if !m.sets[key][string(member)] {
+			m.sets[key][string(member)] = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockStructures) SRem(ctx context.Context, key string, members [][]byte) (int64, error) {
+	var n int64
+	for _, member := range members {
+		if m.sets[key][string(member)] {
+			delete(m.sets[key], string(member))
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockStructures) SMembers(ctx context.Context, key string) ([][]byte, error) {
+	var members [][]byte
+	for member := range m.sets[key] {
+		members = append(members, []byte(member))
+	}
+	return members, nil
+}
+
+func (m *mockStructures) SIsMember(ctx context.Context, key string, member []byte) (bool, error) {
+	return m.sets[key][string(member)], nil
+}
+
+func (m *mockStructures) ZAdd(ctx context.Context, key string, members []Scored[[]byte], ttl time.Duration) (int64, error) {
+	if m.zsets[key] == nil {
+		m.zsets[key] = make(map[string]float64)
+	}
+	var n int64
+	for _, member := range members {
+		if _, ok := m.zsets[key][string(member.Member)]; !ok {
+			n++
+		}
+		m.zsets[key][string(member.Member)] = member.Score
+	}
+	return n, nil
+}
+
+func (m *mockStructures) ZIncrBy(ctx context.Context, key string, member []byte, delta float64) (float64, error) {
+	if m.zsets[key] == nil {
+		m.zsets[key] = make(map[string]float64)
+	}
+	m.zsets[key][string(member)] += delta
+	return m.zsets[key][string(member)], nil
+}
+
+func (m *mockStructures) ZScore(ctx context.Context, key string, member []byte) (float64, error) {
+	score, ok := m.zsets[key][string(member)]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return score, nil
+}
+
+func (m *mockStructures) ZRem(ctx context.Context, key string, members [][]byte) (int64, error) {
+	var n int64
+	for _, member := range members {
+		if _, ok := m.zsets[key][string(member)]; ok {
+			delete(m.zsets[key], string(member))
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockStructures) sorted(key string, rev bool) []Scored[[]byte] {
+	var members []Scored[[]byte]
+	for member, score := range m.zsets[key] {
+		members = append(members, Scored[[]byte]{Member: []byte(member), Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if rev {
+			return members[i].Score > members[j].Score
+		}
+		return members[i].Score < members[j].Score
+	})
+	return members
+}
+
+func (m *mockStructures) ZRangeByScore(ctx context.Context, key string, lo, hi float64, offset, count int64, rev bool) ([]Scored[[]byte], error) {
+	var members []Scored[[]byte]
+	for _, member := range m.sorted(key, rev) {
+		if member.Score >= lo && member.Score <= hi {
+			members = append(members, member)
+		}
+	}
+	members = members[min(int(offset), len(members)):]
+	if count > 0 {
+		members = members[:min(int(count), len(members))]
+	}
+	return members, nil
+}
+
+func (m *mockStructures) ZRangeByRank(ctx context.Context, key string, start, stop int64, rev bool) ([]Scored[[]byte], error) {
+	members := m.sorted(key, rev)
+	if stop < 0 {
+		stop += int64(len(members))
+	}
+	return members[start : stop+1], nil
+}
+
+func TestHash(t *testing.T) {
+	ctx := context.Background()
+	c := newMockStructures()
+
+	type profile struct {
+		Name string `json:"name"`
+	}
+
+	if err := HSet(ctx, c, "user:1", map[string]profile{"a": {"Ann"}, "b": {"Bob"}}, time.Minute); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	if c.ttls["user:1"] != time.Minute {
+		t.Errorf("expected the ttl to be passed, got %s", c.ttls["user:1"])
+	}
+
+	if p, err := HGet[profile](ctx, c, "user:1", "a"); err != nil || p.Name != "Ann" {
+		t.Errorf("expected Ann, got %v (%v)", p, err)
+	}
+	if _, err := HGet[profile](ctx, c, "user:1", "z"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_ = HDel(ctx, c, "user:1", "b")
+	all, err := HGetAll[profile](ctx, c, "user:1")
+	if err != nil || len(all) != 1 || all["a"].Name != "Ann" {
+		t.Errorf("expected only a, got %v (%v)", all, err)
+	}
+
+	// The codec can be overridden per call.
+	_ = HSet(ctx, c, "user:2", map[string]int{"n": 1}, 0, WithCallCodec(Gob))
+	if _, err := HGet[int](ctx, c, "user:2", "n"); err == nil {
+		t.Error("expected JSON to fail on a Gob value")
+	}
+	if n, err := HGet[int](ctx, c, "user:2", "n", WithCallCodec(Gob)); err != nil || n != 1 {
+		t.Errorf("expected 1, got %d (%v)", n, err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	ctx := context.Background()
+	c := newMockStructures()
+
+	if n, err := SAdd(ctx, c, "seen", []string{"a", "b", "a"}, 0); err != nil || n != 2 {
+		t.Errorf("expected 2 added, got %d (%v)", n, err)
+	}
+	if ok, _ := SIsMember(ctx, c, "seen", "a"); !ok {
+		t.Error("expected a to be a member")
+	}
+	if n, _ := SRem(ctx, c, "seen", []string{"a", "z"}); n != 1 {
+		t.Errorf("expected 1 removed, got %d", n)
+	}
+
+	members, err := SMembers[string](ctx, c, "seen")
+	if err != nil || !slices.Equal(members, []string{"b"}) {
+		t.Errorf("expected [b], got %v (%v)", members, err)
+	}
+}
+
+// gobStructures is a mockStructures whose values are encoded with Gob.
+type gobStructures struct {
+	*mockStructures
+}
+
+func (gobStructures) Codec() Codec { return Gob }
+
+func TestSet_Members(t *testing.T) {
+	ctx := context.Background()
+	c := gobStructures{newMockStructures()}
+
+	// Strings are stored as they are, whatever the codec of the cache.
+	_, _ = SAdd(ctx, c, "seen", []string{"a"}, 0)
+	if !c.sets["seen"]["a"] {
+		t.Errorf("expected a stored as is, got %v", c.sets["seen"])
+	}
+
+	// Maps encode the same every time, so equal members are found.
+	member := func() map[string]int {
+		m := make(map[string]int)
+		for i := range 20 {
+			m[strconv.Itoa(i)] = i
+		}
+		return m
+	}
+	if n, err := SAdd(ctx, c, "maps", []map[string]int{member(), member()}, 0); err != nil || n != 1 {
+		t.Errorf("expected 1 added, got %d (%v)", n, err)
+	}
+	if ok, _ := SIsMember(ctx, c, "maps", member()); !ok {
+		t.Error("expected an equal map to be a member")
+	}
+	_, _ = ZAdd(ctx, c, "board", []Scored[map[string]int]{{member(), 1}}, 0)
+	if score, err := ZScore(ctx, c, "board", member()); err != nil || score != 1 {
+		t.Errorf("expected 1, got %v (%v)", score, err)
+	}
+	if n, _ := SRem(ctx, c, "maps", []map[string]int{member()}); n != 1 {
+		t.Errorf("expected 1 removed, got %d", n)
+	}
+}
+
+func TestSortedSet(t *testing.T) {
+	ctx := context.Background()
+	c := newMockStructures()
+
+	_, err := ZAdd(ctx, c, "board", []Scored[string]{{"ann", 10}, {"bob", 30}, {"cid", 20}}, 0)
+	if err != nil {
+		t.Fatalf("ZAdd failed: %v", err)
+	}
+	if score, _ := ZIncrBy(ctx, c, "board", "ann", 25); score != 35 {
+		t.Errorf("expected 35, got %v", score)
+	}
+	if score, _ := ZScore(ctx, c, "board", "cid"); score != 20 {
+		t.Errorf("expected 20, got %v", score)
+	}
+	if _, err := ZScore(ctx, c, "board", "dan"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	names := func(members []Scored[string]) []string {
+		var out []string
+		for _, m := range members {
+			out = append(out, m.Member)
+		}
+		return out
+	}
+
+	top, _ := ZRangeByRank[string](ctx, c, "board", 0, 1, WithReverse())
+	if got := names(top); !slices.Equal(got, []string{"ann", "bob"}) {
+		t.Errorf("expected the top 2, got %v", got)
+	}
+
+	mid, _ := ZRangeByScore[string](ctx, c, "board", 15, math.Inf(1), WithLimit(1, 1))
+	if got := names(mid); !slices.Equal(got, []string{"bob"}) {
+		t.Errorf("expected the second member above 15, got %v", got)
+	}
+
+	if n, _ := ZRem(ctx, c, "board", []string{"bob"}); n != 1 {
+		t.Errorf("expected 1 removed, got %d", n)
+	}
+}
+
+func TestStructures_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	if err := HSet(ctx, m, "k", map[string]int{"a": 1}, 0); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for hashes, got %v", err)
+	}
+	if _, err := SAdd(ctx, m, "k", []int{1}, 0); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for sets, got %v", err)
+	}
+	if _, err := ZRangeByRank[int](ctx, m, "k", 0, -1); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for sorted sets, got %v", err)
+	}
+}
+
+// mockRedisStructures records the ZRANGE arguments and misses every lookup.
+type mockRedisStructures struct {
+	redis.UniversalClient
+	zrange redis.ZRangeArgs
+}
+
+func (m *mockRedisStructures) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	cmd := redis.NewStringCmd(ctx)
+	cmd.SetErr(redis.Nil)
+	return cmd
+}
+
+func (m *mockRedisStructures) ZScore(ctx context.Context, key, member string) *redis.FloatCmd {
+	cmd := redis.NewFloatCmd(ctx)
+	cmd.SetErr(redis.Nil)
+	return cmd
+}
+
+func (m *mockRedisStructures) ZRangeArgsWithScores(ctx context.Context, z redis.ZRangeArgs) *redis.ZSliceCmd {
+	m.zrange = z
+	cmd := redis.NewZSliceCmd(ctx)
+	cmd.SetVal([]redis.Z{{Member: "a", Score: 1}})
+	return cmd
+}
+
+func TestRedis_Structures(t *testing.T) {
+	ctx := context.Background()
+	m := &mockRedisStructures{}
+	r := &RedisCache{client: m}
+
+	if _, err := r.HGet(ctx, "h", "f"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from HGet, got %v", err)
+	}
+	if _, err := r.ZScore(ctx, "z", []byte("m")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from ZScore, got %v", err)
+	}
+
+	members, err := r.ZRangeByScore(ctx, "z", math.Inf(-1), 5, 10, 0, true)
+	if err != nil || len(members) != 1 || string(members[0].Member) != "a" {
+		t.Fatalf("unexpected members %v (%v)", members, err)
+	}
+	want := redis.ZRangeArgs{Key: "z", Start: "-inf", Stop: "5", ByScore: true, Rev: true, Offset: 10, Count: -1}
+	if m.zrange != want {
+		t.Errorf("expected %+v, got %+v", want, m.zrange)
+	}
+}
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// swrEntry is the value stored by RememberSWR: the result plus the soft expiry
+// after which it is served stale, and how long fn took to compute it.
+type swrEntry[T any] struct {
+	Value      T
+	SoftExpiry int64 // unix milliseconds
+	Delta      int64 // milliseconds
+}
+
+// swrCodec stores an swrEntry as a fixed header followed by the value encoded
+// with the underlying codec, so RememberSWR works with any codec.
+type swrCodec struct {
+	codec Codec
+}
+
+const swrHeaderSize = 16
+
+// RememberSWR is Remember with stale-while-revalidate semantics. Values are
+// kept for hardTTL but considered fresh for softTTL only; once stale they are
+// still returned while fn refreshes the key in the background. Only a miss
+// blocks on fn.
+//
+// With WithEarlyRefresh, fresh values are also refreshed early with a
+// probability that grows as the soft expiry approaches (XFetch), spreading
+// refreshes of keys that were written at the same time.
+func RememberSWR[T any](ctx context.Context, c Cache, key string, softTTL, hardTTL time.Duration, fn func() (T, error), opts ...CallOption) (T, error) {
+	var zero T
+
+	cfg := newCallConfig(opts)
+	cfg.codec = swrCodec{codec: codecFor(c, cfg)}
+	opts = append(opts[:len(opts):len(opts)], WithCallCodec(cfg.codec))
+
+	fnEntry := func() (swrEntry[T], error) {
+		start := time.Now()
+		value, err := fn()
+		if err != nil {
+			return swrEntry[T]{}, err
+		}
+
+		now := time.Now()
+		return swrEntry[T]{
+			Value:      value,
+			SoftExpiry: now.Add(softTTL).UnixMilli(),
+			Delta:      now.Sub(start).Milliseconds(),
+		}, nil
+	}
+
+	entry, err := Remember(ctx, c, key, hardTTL, fnEntry, opts...)
+	if err != nil {
+		return zero, err
+	}
+
+	if entry.stale(time.Now(), cfg.earlyRefreshBeta) {
+		refresh(context.WithoutCancel(ctx), c, key, hardTTL, fnEntry, cfg)
+	}
+
+	return entry.Value, nil
+}
+
+// WithEarlyRefresh enables probabilistic early refresh in RememberSWR. beta
+// scales how early refreshes happen: 1 is the usual choice, larger values
+// refresh earlier and zero disables early refresh.
+func WithEarlyRefresh(beta float64) CallOption {
+	return func(c *callConfig) {
+		c.earlyRefreshBeta = beta
+	}
+}
+
+// WithRefreshErrorHandler sets a function called when a background refresh
+// started by RememberSWR fails. Errors are dropped by default.
+func WithRefreshErrorHandler(fn func(key string, err error)) CallOption {
+	return func(c *callConfig) {
+		c.refreshErrorHandler = fn
+	}
+}
+
+// refresh recomputes key in the background. Refreshes of the same key of the
+// same cache are coalesced within this process and, with WithLock, across
+// replicas.
+func refresh[T any](ctx context.Context, c Cache, key string, ttl time.Duration, fn func() (T, error), cfg *callConfig) {
+	run := func() (any, error) {
+		err := refreshOnce(ctx, c, key, ttl, fn, cfg)
+		if err != nil && cfg.refreshErrorHandler != nil {
+			cfg.refreshErrorHandler(key, err)
+		}
+
+		return nil, err
+	}
+
+	flight, ok := flightKey(c, "refresh", key)
+	if !ok {
+		go func() { _, _ = run() }()
+		return
+	}
+	flights.DoChan(flight, run)
+}
+
+func refreshOnce[T any](ctx context.Context, c Cache, key string, ttl time.Duration, fn func() (T, error), cfg *callConfig) error {
+	if a, ok := c.(Adder); ok && cfg.lockTTL > 0 {
+		lock, token := lockKey(key), lockToken()
+		acquired, err := a.Add(ctx, lock, token, cfg.lockTTL)
+		if err != nil || !acquired {
+			// Another replica is already refreshing.
+			return err
+		}
+		defer releaseLock(ctx, c, lock, token)
+	}
+
+	_, err := compute(ctx, c, key, ttl, fn, cfg)
+	return err
+}
+
+func (e swrEntry[T]) stale(now time.Time, beta float64) bool {
+	expiry := time.UnixMilli(e.SoftExpiry)
+	if beta <= 0 {
+		return !now.Before(expiry)
+	}
+
+	// XFetch: refresh when now - delta * beta * ln(rand) >= expiry.
+	early := time.Duration(float64(e.Delta) * beta * -math.Log(1-rand.Float64()) * float64(time.Millisecond))
+	return !now.Add(early).Before(expiry)
+}
+
+func (e swrEntry[T]) header() (int64, int64) {
+	return e.SoftExpiry, e.Delta
+}
+
+func (e swrEntry[T]) value() any {
+	return e.Value
+}
+
+func (e *swrEntry[T]) setHeader(softExpiry, delta int64) {
+	e.SoftExpiry, e.Delta = softExpiry, delta
+}
+
+func (e *swrEntry[T]) valuePtr() any {
+	return &e.Value
+}
+
+func (c swrCodec) Marshal(v any) ([]byte, error) {
+	e, ok := v.(interface {
+		header() (int64, int64)
+		value() any
+	})
+	if !ok {
+		return nil, fmt.Errorf("cache: %T is not a stale-while-revalidate entry", v)
+	}
+
+	data, err := c.codec.Marshal(e.value())
+	if err != nil {
+		return nil, err
+	}
+
+	softExpiry, delta := e.header()
+	buf := make([]byte, swrHeaderSize, swrHeaderSize+len(data))
+	binary.BigEndian.PutUint64(buf[0:8], uint64(softExpiry))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(delta))
+
+	return append(buf, data...), nil
+}
+
+func (c swrCodec) Unmarshal(data []byte, v any) error {
+	e, ok := v.(interface {
+		setHeader(softExpiry, delta int64)
+		valuePtr() any
+	})
+	if !ok {
+		return fmt.Errorf("cache: %T is not a stale-while-revalidate entry", v)
+	}
+	if len(data) < swrHeaderSize {
+		return errors.New("cache: invalid stale-while-revalidate entry")
+	}
+
+	e.setHeader(int64(binary.BigEndian.Uint64(data[0:8])), int64(binary.BigEndian.Uint64(data[8:16])))
+
+	return c.codec.Unmarshal(data[swrHeaderSize:], e.valuePtr())
+}
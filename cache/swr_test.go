@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRememberSWR(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	var calls atomic.Int32
+	fn := func() (int32, error) {
+		return calls.Add(1), nil
+	}
+
+	// 1. Miss - computes synchronously
+	got, err := RememberSWR(ctx, m, "key", 20*time.Millisecond, time.Minute, fn)
+	if err != nil {
+		t.Fatalf("RememberSWR failed: %v", err)
+	}
+	if got != 1 {
+		t.Errorf("expected 1, got %d", got)
+	}
+
+	// 2. Fresh hit - no refresh
+	got, _ = RememberSWR(ctx, m, "key", 20*time.Millisecond, time.Minute, fn)
+	if got != 1 || calls.Load() != 1 {
+		t.Errorf("expected fresh value without refresh, got %d after %d calls", got, calls.Load())
+	}
+
+	// 3. Stale hit - serves the old value and refreshes in the background
+	time.Sleep(30 * time.Millisecond)
+	got, _ = RememberSWR(ctx, m, "key", 20*time.Millisecond, time.Minute, fn)
+	if got != 1 {
+		t.Errorf("expected stale value 1, got %d", got)
+	}
+
+	waitFor(t, func() bool {
+		got, _ := RememberSWR(ctx, m, "key", time.Minute, time.Minute, fn)
+		return got == 2
+	})
+}
+
+func TestRememberSWR_Error(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	fnErr := errors.New("fn error")
+	_, err := RememberSWR(ctx, m, "key", time.Second, time.Minute, func() (int, error) {
+		return 0, fnErr
+	})
+	if !errors.Is(err, fnErr) {
+		t.Fatalf("expected %v, got %v", fnErr, err)
+	}
+}
+
+func TestRememberSWR_RefreshError(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	_ = Set(ctx, m, "key", swrEntry[int]{Value: 1}, time.Minute, WithCallCodec(swrCodec{codec: JSON}))
+
+	fnErr := errors.New("fn error")
+	errs := make(chan error, 1)
+	got, err := RememberSWR(ctx, m, "key", time.Second, time.Minute, func() (int, error) {
+		return 0, fnErr
+	}, WithRefreshErrorHandler(func(key string, err error) {
+		errs <- err
+	}))
+	if err != nil {
+		t.Fatalf("RememberSWR failed: %v", err)
+	}
+	if got != 1 {
+		t.Errorf("expected stale value 1, got %d", got)
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, fnErr) {
+			t.Errorf("expected %v, got %v", fnErr, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected refresh error to be reported")
+	}
+}
+
+func TestRememberSWR_EarlyRefresh(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	// Fresh for another minute, but expensive enough that XFetch refreshes it early
+	_ = Set(ctx, m, "key", swrEntry[int]{
+		Value:      1,
+		SoftExpiry: time.Now().Add(time.Minute).UnixMilli(),
+		Delta:      time.Hour.Milliseconds(),
+	}, time.Hour, WithCallCodec(swrCodec{codec: JSON}))
+
+	fn := func() (int, error) {
+		return 2, nil
+	}
+
+	got, _ := RememberSWR(ctx, m, "key", time.Minute, time.Hour, fn, WithEarlyRefresh(1000))
+	if got != 1 {
+		t.Errorf("expected current value 1, got %d", got)
+	}
+
+	waitFor(t, func() bool {
+		got, _ := RememberSWR(ctx, m, "key", time.Minute, time.Hour, fn)
+		return got == 2
+	})
+}
+
+func TestRememberSWR_RefreshLocked(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithCleanupInterval(0))
+	defer m.Close()
+
+	_ = Set(ctx, m, "key", swrEntry[int]{Value: 1}, time.Minute, WithCallCodec(swrCodec{codec: JSON}))
+	_, _ = m.Add(ctx, "key:lock", []byte("other"), time.Minute)
+
+	var calls atomic.Int32
+	fn := func() (int, error) {
+		calls.Add(1)
+		return 2, nil
+	}
+
+	// Another replica holds the refresh lock, so we keep serving stale data
+	got, _ := RememberSWR(ctx, m, "key", time.Second, time.Minute, fn, WithLock(time.Second))
+	if got != 1 {
+		t.Errorf("expected stale value 1, got %d", got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected no refresh while another replica holds the lock, got %d calls", n)
+	}
+
+	_ = m.Del(ctx, "key:lock")
+	_, _ = RememberSWR(ctx, m, "key", time.Second, time.Minute, fn, WithLock(time.Second))
+
+	waitFor(t, func() bool {
+		_, err := m.Get(ctx, "key:lock")
+		return calls.Load() == 1 && errors.Is(err, ErrNotFound)
+	})
+}
+
+func TestSWRCodec(t *testing.T) {
+	codec := swrCodec{codec: Gob}
+
+	data, err := codec.Marshal(swrEntry[string]{Value: "v", SoftExpiry: 1, Delta: 2})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var got swrEntry[string]
+	if err := codec.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Value != "v" || got.SoftExpiry != 1 || got.Delta != 2 {
+		t.Errorf("unexpected entry %+v", got)
+	}
+
+	if _, err := codec.Marshal("plain"); err == nil {
+		t.Error("expected error marshaling a non-entry value")
+	}
+	if err := codec.Unmarshal(data, new(string)); err == nil {
+		t.Error("expected error unmarshaling into a non-entry value")
+	}
+	if err := codec.Unmarshal([]byte("short"), &got); err == nil {
+		t.Error("expected error on truncated entry")
+	}
+}
+
+func TestSWREntry_Stale(t *testing.T) {
+	now := time.Now()
+	e := swrEntry[int]{SoftExpiry: now.UnixMilli()}
+
+	if !e.stale(now.Add(time.Millisecond), 0) {
+		t.Error("expected entry past its soft expiry to be stale")
+	}
+	if e.stale(now.Add(-time.Second), 0) {
+		t.Error("expected entry before its soft expiry to be fresh")
+	}
+	if e.stale(now.Add(-time.Second), 1) {
+		t.Error("expected entry with no compute time not to refresh early")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRememberSWR_RefreshPerCache(t *testing.T) {
+	ctx := context.Background()
+	a := NewMemory(WithCleanupInterval(0))
+	defer a.Close()
+	b := NewMemory(WithCleanupInterval(0))
+	defer b.Close()
+
+	for _, m := range []*MemoryCache{a, b} {
+		_ = Set(ctx, m, "key", swrEntry[int]{Value: 1}, time.Minute, WithCallCodec(swrCodec{codec: JSON}))
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	_, _ = RememberSWR(ctx, a, "key", time.Second, time.Minute, func() (int, error) {
+		<-release
+		return 2, nil
+	})
+
+	// The refresh of a is still running; it must not suppress the one of b.
+	_, _ = RememberSWR(ctx, b, "key", time.Second, time.Minute, func() (int, error) {
+		return 3, nil
+	})
+	waitFor(t, func() bool {
+		got, _ := RememberSWR(ctx, b, "key", time.Minute, time.Hour, func() (int, error) { return 0, nil })
+		return got == 3
+	})
+}
@@ -0,0 +1,320 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+	"time"
+)
+
+// Invalidator is implemented by backends that report keys changed on the
+// server by any client, so that copies kept in process can be dropped. fn
+// receives nil when every key may have changed, e.g. after a reconnect.
+type Invalidator interface {
+	OnInvalidate(fn func(keys []string))
+}
+
+// TieredCache layers an in-process MemoryCache (L1) over a remote Cache (L2).
+// Reads are served from L1 when possible and fill it from L2 on a miss; writes
+// and deletes go through to both tiers. When the remote backend implements
+// Invalidator, L1 entries are dropped as soon as another client changes them,
+// otherwise they are only bounded by the near TTL.
+type TieredCache struct {
+	near        *MemoryCache
+	remote      Cache
+	nearTTL     time.Duration
+	nearFillTTL time.Duration
+
+	mu    sync.Mutex
+	fills map[string]uint64 // L1 fills in flight, cancelled when their key changes
+	seq   uint64
+}
+
+// Default bounds of the L1, which must stay small and short-lived as it is
+// only invalidated when the remote tier reports changes.
+const (
+	defaultNearTTL        = time.Minute
+	defaultNearFillTTL    = 5 * time.Second
+	defaultNearMaxEntries = 10_000
+)
+
+// NewTiered returns a TieredCache over remote. The L1 holds up to
+// defaultNearMaxEntries entries unless WithMaxEntries is given, for at most
+// the near TTL, which defaults to a minute when it is not positive. Values
+// read from the remote tier are kept for the shorter near fill TTL, as their
+// remaining remote TTL is unknown.
+func NewTiered(remote Cache, opts ...Option) *TieredCache {
+	cfg := &Config{
+		NearTTL:     defaultNearTTL,
+		NearFillTTL: defaultNearFillTTL,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.NearTTL <= 0 {
+		cfg.NearTTL = defaultNearTTL
+	}
+	if cfg.NearFillTTL <= 0 {
+		cfg.NearFillTTL = defaultNearFillTTL
+	}
+
+	t := &TieredCache{
+		near:        NewMemory(append([]Option{WithMaxEntries(defaultNearMaxEntries)}, opts...)...),
+		remote:      remote,
+		nearTTL:     cfg.NearTTL,
+		nearFillTTL: min(cfg.NearFillTTL, cfg.NearTTL),
+		fills:       make(map[string]uint64),
+	}
+
+	if inv, ok := remote.(Invalidator); ok {
+		inv.OnInvalidate(t.invalidate)
+	}
+
+	return t
+}
+
+// Unwrap returns the remote cache.
+func (t *TieredCache) Unwrap() Cache {
+	return t.remote
+}
+
+// Codec returns the codec of the remote cache, as both tiers hold the same bytes.
+func (t *TieredCache) Codec() Codec {
+	if cc, ok := t.remote.(interface{ Codec() Codec }); ok {
+		return cc.Codec()
+	}
+
+	return nil
+}
+
+// Set writes L1 before the remote tier, so an invalidation delivered for the
+// remote write cannot be overwritten by the L1 write that follows it.
+func (t *TieredCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	t.mu.Lock()
+	delete(t.fills, key)
+	_ = t.near.Set(ctx, key, value, t.nearTTLFor(ttl))
+	t.mu.Unlock()
+
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		t.drop(key)
+		return err
+	}
+
+	return nil
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if val, err := t.near.Get(ctx, key); err == nil {
+		return val, nil
+	}
+
+	seq := t.beginFill(key)
+	val, err := t.remote.Get(ctx, key)
+	if err != nil {
+		t.cancelFill(key, seq)
+		return nil, err
+	}
+
+	t.fill(key, seq, val)
+	return val, nil
+}
+
+func (t *TieredCache) Del(ctx context.Context, key string) error {
+	err := t.remote.Del(ctx, key)
+	t.drop(key)
+
+	return err
+}
+
+// Add stores value in the remote cache only when the key does not exist there
+// yet. It returns errors.ErrUnsupported when the remote cache is not an Adder.
+func (t *TieredCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	a, ok := t.remote.(Adder)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+
+	added, err := a.Add(ctx, key, value, ttl)
+	if added {
+		t.drop(key)
+	}
+
+	return added, err
+}
+
+func (t *TieredCache) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	data, _ := t.near.GetMany(ctx, keys)
+
+	var missing []string
+	seqs := make(map[string]uint64)
+	for _, key := range keys {
+		if _, ok := data[key]; !ok {
+			missing = append(missing, key)
+			seqs[key] = t.beginFill(key)
+		}
+	}
+	if len(missing) == 0 {
+		return data, nil
+	}
+
+	remote, err := getMany(ctx, t.remote, missing)
+	for _, key := range missing {
+		val, ok := remote[key]
+		if err != nil || !ok {
+			t.cancelFill(key, seqs[key])
+			continue
+		}
+
+		t.fill(key, seqs[key], val)
+		data[key] = val
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (t *TieredCache) SetMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	// L1 first, like Set.
+	t.mu.Lock()
+	for _, key := range keys {
+		delete(t.fills, key)
+	}
+	_ = t.near.SetMany(ctx, items, t.nearTTLFor(ttl))
+	t.mu.Unlock()
+
+	if err := setMany(ctx, t.remote, items, ttl); err != nil {
+		t.drop(keys...)
+		return err
+	}
+
+	return nil
+}
+
+func (t *TieredCache) DelMany(ctx context.Context, keys []string) error {
+	err := DelMany(ctx, t.remote, keys)
+	t.drop(keys...)
+
+	return err
+}
+
+// Scan lists the keys of the remote cache matching pattern. It returns
+// errors.ErrUnsupported when the remote cache is not a Scanner.
+func (t *TieredCache) Scan(ctx context.Context, pattern string) iter.Seq2[string, error] {
+	return Scan(ctx, t.remote, pattern)
+}
+
+// DelPattern deletes the keys matching pattern from the remote cache and
+// drops every L1 entry, as a pattern may match keys filled since the scan.
+func (t *TieredCache) DelPattern(ctx context.Context, pattern string) (int64, error) {
+	n, err := DelPattern(ctx, t.remote, pattern)
+	t.invalidate(nil)
+
+	return n, err
+}
+
+// Ping checks the remote cache.
+func (t *TieredCache) Ping(ctx context.Context) error {
+	return Ping(ctx, t.remote)
+}
+
+// Close stops the L1 janitor. The remote cache is left open.
+func (t *TieredCache) Close() error {
+	return t.near.Close()
+}
+
+// nearTTLFor caps the L1 TTL of a write by the TTL given for the remote tier,
+// zero when unknown or unbounded.
+func (t *TieredCache) nearTTLFor(ttl time.Duration) time.Duration {
+	if ttl <= 0 || t.nearTTL < ttl {
+		return t.nearTTL
+	}
+
+	return ttl
+}
+
+// beginFill records that key is being read from the remote tier. The value is
+// only copied to L1 if the key was not written or invalidated meanwhile,
+// otherwise a stale read could outlive the invalidation that should drop it.
+func (t *TieredCache) beginFill(key string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	t.fills[key] = t.seq
+	return t.seq
+}
+
+func (t *TieredCache) fill(key string, seq uint64, value []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.fills[key] != seq {
+		return
+	}
+
+	delete(t.fills, key)
+	_ = t.near.Set(context.Background(), key, value, t.nearFillTTL)
+}
+
+func (t *TieredCache) cancelFill(key string, seq uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.fills[key] == seq {
+		delete(t.fills, key)
+	}
+}
+
+func (t *TieredCache) drop(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range keys {
+		delete(t.fills, key)
+	}
+	_ = t.near.DelMany(context.Background(), keys)
+}
+
+func (t *TieredCache) invalidate(keys []string) {
+	if keys != nil {
+		t.drop(keys...)
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clear(t.fills)
+	t.near.clear()
+}
+
+// invalidationHandlers fans out server-assisted invalidations to every
+// subscribed TieredCache.
+type invalidationHandlers struct {
+	mu  sync.RWMutex
+	fns []func(keys []string)
+}
+
+func (h *invalidationHandlers) add(fn func(keys []string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.fns = append(h.fns, fn)
+}
+
+func (h *invalidationHandlers) notify(keys []string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, fn := range h.fns {
+		fn(keys)
+	}
+}
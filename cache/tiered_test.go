@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// invalidatingMemory is a remote tier that reports invalidations like Redis
+// and Valkey do with WithTracking.
+type invalidatingMemory struct {
+	*MemoryCache
+	invalidations invalidationHandlers
+	trackWrites   bool // report every write, like WithTracking in broadcast mode
+}
+
+func (m *invalidatingMemory) OnInvalidate(fn func(keys []string)) {
+	m.invalidations.add(fn)
+}
+
+func (m *invalidatingMemory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	err := m.MemoryCache.Set(ctx, key, value, ttl)
+	if m.trackWrites {
+		m.invalidations.notify([]string{key})
+	}
+
+	return err
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory(WithCleanupInterval(0))
+	c := NewTiered(remote, WithCleanupInterval(0))
+	defer c.Close()
+
+	if err := c.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if val, _ := remote.Get(ctx, "key"); string(val) != "value" {
+		t.Errorf("expected write-through to the remote tier, got %q", val)
+	}
+
+	// A remote change without invalidation is hidden by L1
+	_ = remote.Set(ctx, "key", []byte("other"), time.Minute)
+	if val, _ := c.Get(ctx, "key"); string(val) != "value" {
+		t.Errorf("expected value from L1, got %q", val)
+	}
+
+	if err := c.Del(ctx, "key"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	if _, err := remote.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected delete-through to the remote tier, got %v", err)
+	}
+	if _, err := c.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after Del, got %v", err)
+	}
+
+	// A miss in L1 is filled from the remote tier
+	_ = remote.Set(ctx, "key", []byte("remote"), time.Minute)
+	if val, err := c.Get(ctx, "key"); err != nil || string(val) != "remote" {
+		t.Fatalf("expected remote, got %q, %v", val, err)
+	}
+	_ = remote.Del(ctx, "key")
+	if val, _ := c.Get(ctx, "key"); string(val) != "remote" {
+		t.Errorf("expected L1 to be filled, got %q", val)
+	}
+}
+
+func TestTiered_NearTTL(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory(WithCleanupInterval(0))
+	c := NewTiered(remote, WithCleanupInterval(0), WithNearTTL(time.Second))
+	defer c.Close()
+
+	now := time.Now()
+	c.near.now = func() time.Time { return now }
+
+	_ = c.Set(ctx, "key", []byte("value"), time.Hour)
+	_ = remote.Set(ctx, "key", []byte("other"), time.Hour)
+
+	now = now.Add(2 * time.Second)
+	if val, _ := c.Get(ctx, "key"); string(val) != "other" {
+		t.Errorf("expected L1 entry to expire after the near TTL, got %q", val)
+	}
+
+	if got := c.nearTTLFor(100 * time.Millisecond); got != 100*time.Millisecond {
+		t.Errorf("expected the remote TTL to cap the near TTL, got %v", got)
+	}
+	if got := c.nearTTLFor(0); got != time.Second {
+		t.Errorf("expected the near TTL for keys that never expire, got %v", got)
+	}
+}
+
+func TestTiered_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	remote := &invalidatingMemory{MemoryCache: NewMemory(WithCleanupInterval(0))}
+	c := NewTiered(remote, WithCleanupInterval(0))
+	defer c.Close()
+
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("2"), time.Minute)
+
+	_ = remote.Set(ctx, "a", []byte("changed"), time.Minute)
+	remote.invalidations.notify([]string{"a"})
+	if val, _ := c.Get(ctx, "a"); string(val) != "changed" {
+		t.Errorf("expected invalidated key to be read from the remote tier, got %q", val)
+	}
+
+	_ = remote.Set(ctx, "b", []byte("changed"), time.Minute)
+	remote.invalidations.notify(nil)
+	if c.near.Len() != 0 {
+		t.Errorf("expected L1 to be cleared, got %d entries", c.near.Len())
+	}
+	if val, _ := c.Get(ctx, "b"); string(val) != "changed" {
+		t.Errorf("expected b from the remote tier, got %q", val)
+	}
+
+	// An invalidation racing a fill keeps the stale read out of L1
+	seq := c.beginFill("a")
+	remote.invalidations.notify([]string{"a"})
+	c.fill("a", seq, []byte("stale"))
+	if _, err := c.near.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected cancelled fill, got %v", err)
+	}
+
+	// An invalidation delivered during the remote write is not overwritten
+	// by the L1 write.
+	remote.trackWrites = true
+	_ = c.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, err := c.near.Get(ctx, "c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the invalidation to drop the L1 write, got %v", err)
+	}
+}
+
+func TestTiered_Batch(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory(WithCleanupInterval(0))
+	c := NewTiered(remote, WithCleanupInterval(0))
+	defer c.Close()
+
+	if err := SetMany(ctx, c, map[string]string{"a": "1", "b": "2"}, time.Minute); err != nil {
+		t.Fatalf("SetMany failed: %v", err)
+	}
+	_ = remote.Set(ctx, "c", []byte(`"3"`), time.Minute)
+
+	hits, misses, err := GetMany[string](ctx, c, []string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+	if len(hits) != 3 || hits["c"] != "3" || len(misses) != 1 {
+		t.Errorf("unexpected hits %v misses %v", hits, misses)
+	}
+	if c.near.Len() != 3 {
+		t.Errorf("expected remote hits to fill L1, got %d entries", c.near.Len())
+	}
+
+	if err := DelMany(ctx, c, []string{"a", "b", "c"}); err != nil {
+		t.Fatalf("DelMany failed: %v", err)
+	}
+	if c.near.Len() != 0 || remote.Len() != 0 {
+		t.Errorf("expected both tiers to be empty, got %d and %d", c.near.Len(), remote.Len())
+	}
+}
+
+func TestTiered_Errors(t *testing.T) {
+	ctx := context.Background()
+	wantErr := errors.New("backend error")
+	remote := &mockCache{data: make(map[string][]byte)}
+	c := NewTiered(remote, WithCleanupInterval(0))
+	defer c.Close()
+
+	_ = c.Set(ctx, "key", []byte("value"), time.Minute)
+	remote.err = wantErr
+
+	// A failed write must not leave the previous value in L1
+	if err := c.Set(ctx, "key", []byte("new"), time.Minute); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if _, err := c.Get(ctx, "key"); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if _, err := c.GetMany(ctx, []string{"key"}); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if err := c.SetMany(ctx, map[string][]byte{"key": []byte("value")}, time.Minute); !errors.Is(err, wantErr) {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if _, err := c.Add(ctx, "key", []byte("value"), time.Minute); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported without an Adder, got %v", err)
+	}
+	if len(c.fills) != 0 {
+		t.Errorf("expected no fills left behind, got %v", c.fills)
+	}
+}
+
+func TestTiered_Remember(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory(WithCleanupInterval(0), WithCodec(MsgPack))
+	c := NewTiered(remote, WithCleanupInterval(0))
+	defer c.Close()
+
+	if c.Codec() != MsgPack {
+		t.Errorf("expected the remote codec")
+	}
+
+	calls := 0
+	fn := func() (string, error) {
+		calls++
+		return "value", nil
+	}
+
+	for range 2 {
+		val, err := Remember(ctx, c, "key", time.Minute, fn, WithLock(time.Second))
+		if err != nil || val != "value" {
+			t.Fatalf("Remember failed: %q, %v", val, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+	if _, err := remote.Get(ctx, "key:lock"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected lock to be released, got %v", err)
+	}
+}
+
+func TestTiered_NearBounds(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory(WithCleanupInterval(0))
+	c := NewTiered(remote, WithNearTTL(0), WithCleanupInterval(0))
+	defer c.Close()
+
+	if c.near.maxEntries != defaultNearMaxEntries {
+		t.Errorf("expected L1 bounded to %d entries, got %d", defaultNearMaxEntries, c.near.maxEntries)
+	}
+
+	// A fill from a remote entry without expiry still expires from L1.
+	_ = remote.Set(ctx, "key", []byte("value"), 0)
+	if _, err := c.Get(ctx, "key"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	c.near.mu.Lock()
+	e, ok := c.near.lookup("key")
+	c.near.mu.Unlock()
+	if !ok || e.expiresAt.IsZero() || time.Until(e.expiresAt) > defaultNearFillTTL {
+		t.Errorf("expected the fill to expire within %s, got %+v", defaultNearFillTTL, e)
+	}
+	short := NewTiered(remote, WithNearTTL(time.Second), WithCleanupInterval(0))
+	defer short.Close()
+	if short.nearFillTTL != time.Second {
+		t.Errorf("expected the near TTL to cap the fill TTL, got %s", short.nearFillTTL)
+	}
+
+	// Writes without a TTL are bounded too.
+	_ = c.Set(ctx, "other", []byte("value"), 0)
+	c.near.mu.Lock()
+	e, _ = c.near.lookup("other")
+	c.near.mu.Unlock()
+	if e == nil || e.expiresAt.IsZero() {
+		t.Error("expected an L1 expiry for a write without TTL")
+	}
+
+	unbounded := NewTiered(remote, WithMaxEntries(0), WithCleanupInterval(0))
+	defer unbounded.Close()
+	if unbounded.near.maxEntries != 0 {
+		t.Errorf("expected WithMaxEntries to override the L1 bound, got %d", unbounded.near.maxEntries)
+	}
+}
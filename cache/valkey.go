@@ -2,7 +2,10 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"strconv"
 	"time"
 
 	"github.com/valkey-io/valkey-go"
@@ -11,6 +14,9 @@ import (
 
 type ValkeyCache struct {
 	client valkey.Client
+	codec  Codec
+
+	invalidations invalidationHandlers
 }
 
 func NewValkey(opts ...Option) (*ValkeyCache, error) {
@@ -19,29 +25,464 @@ func NewValkey(opts ...Option) (*ValkeyCache, error) {
 		opt(cfg)
 	}
 
-	client, err := valkeyotel.NewClient(valkey.ClientOption{
-		InitAddress: []string{fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)},
+	v := &ValkeyCache{codec: cfg.Codec}
+
+	// valkey-go picks cluster mode by itself when the nodes report it.
+	option := valkey.ClientOption{
+		InitAddress: cfg.addrs(),
+		Username:    cfg.Username,
 		Password:    cfg.Password,
 		SelectDB:    cfg.Database,
-	})
+		TLSConfig:   cfg.TLSConfig,
+	}
+	if cfg.MasterName != "" {
+		option.Sentinel = valkey.SentinelOption{
+			MasterSet: cfg.MasterName,
+			TLSConfig: cfg.TLSConfig,
+		}
+	}
+	if cfg.Tracking {
+		option.ClientTrackingOptions = []string{"BCAST"}
+		option.OnInvalidations = v.onInvalidations
+	}
+
+	client, err := valkeyotel.NewClient(option)
 	if err != nil {
 		return nil, err
 	}
+	v.client = client
+
+	return v, nil
+}
+
+// NewValkeyFromClient returns a ValkeyCache over an existing client, so its
+// connections can be shared with other libraries. The client is used as it
+// is: create it with valkeyotel for traces and metrics. Connection options
+// are ignored, WithTracking is not supported as tracking is set up when a
+// client is created, and Close closes the client.
+func NewValkeyFromClient(client valkey.Client, opts ...Option) (*ValkeyCache, error) {
+	cfg := &Config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.Tracking {
+		return nil, fmt.Errorf("cache: WithTracking on an existing Valkey client: %w", errors.ErrUnsupported)
+	}
+
+	return &ValkeyCache{client: client, codec: cfg.Codec}, nil
+}
+
+// OnInvalidate registers fn to be called with the keys changed on the server.
+// It is only called when the cache was created with WithTracking.
+func (v *ValkeyCache) OnInvalidate(fn func(keys []string)) {
+	v.invalidations.add(fn)
+}
+
+// Client returns the instrumented valkey-go client, so commands the Cache
+// interface lacks, and other packages such as messaging, share its
+// connections.
+func (v *ValkeyCache) Client() valkey.Client {
+	return v.client
+}
 
-	return &ValkeyCache{client: client}, nil
+func (v *ValkeyCache) Ping(ctx context.Context) error {
+	return v.client.Do(ctx, v.client.B().Ping().Build()).Error()
+}
+
+func (v *ValkeyCache) Close() error {
+	v.client.Close()
+	return nil
+}
+
+func (v *ValkeyCache) Codec() Codec {
+	return v.codec
 }
 
 func (v *ValkeyCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	cmd := v.client.B().Set().Key(key).Value(string(value)).Ex(ttl).Build()
-	return v.client.Do(ctx, cmd).Error()
+	return v.client.Do(ctx, v.setCmd(key, value, ttl)).Error()
 }
 
 func (v *ValkeyCache) Get(ctx context.Context, key string) ([]byte, error) {
 	cmd := v.client.B().Get().Key(key).Build()
-	return v.client.Do(ctx, cmd).AsBytes()
+	val, err := v.client.Do(ctx, cmd).AsBytes()
+	if valkey.IsValkeyNil(err) {
+		return nil, ErrNotFound
+	}
+
+	return val, err
 }
 
 func (v *ValkeyCache) Del(ctx context.Context, key string) error {
 	cmd := v.client.B().Del().Key(key).Build()
 	return v.client.Do(ctx, cmd).Error()
 }
+
+func (v *ValkeyCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	cmd := v.client.B().Set().Key(key).Value(string(value)).Nx()
+	var err error
+	if ttl > 0 {
+		err = v.client.Do(ctx, cmd.Px(ttl).Build()).Error()
+	} else {
+		err = v.client.Do(ctx, cmd.Build()).Error()
+	}
+
+	if valkey.IsValkeyNil(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (v *ValkeyCache) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	msgs, err := valkey.MGet(v.client, ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(msgs))
+	for key, msg := range msgs {
+		if msg.IsNil() {
+			continue
+		}
+
+		val, err := msg.AsBytes()
+		if err != nil {
+			return nil, err
+		}
+		data[key] = val
+	}
+
+	return data, nil
+}
+
+func (v *ValkeyCache) SetMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	cmds := make(valkey.Commands, 0, len(items))
+	for key, value := range items {
+		cmds = append(cmds, v.setCmd(key, value, ttl))
+	}
+
+	for _, resp := range v.client.DoMulti(ctx, cmds...) {
+		if err := resp.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *ValkeyCache) DelMany(ctx context.Context, keys []string) error {
+	for _, err := range valkey.MDel(v.client, ctx, keys) {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Scan lists the keys matching pattern with SCAN. On a cluster every primary
+// is scanned in turn.
+func (v *ValkeyCache) Scan(ctx context.Context, pattern string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		nodes, err := v.primaries(ctx)
+		if err != nil {
+			yield("", err)
+			return
+		}
+
+		for _, node := range nodes {
+			scanner := valkey.NewScanner(func(cursor uint64) (valkey.ScanEntry, error) {
+				cmd := node.B().Scan().Cursor(cursor).Match(pattern).Count(scanCount).Build()
+				return node.Do(ctx, cmd).AsScanEntry()
+			})
+			for key := range scanner.Iter() {
+				if !yield(key, nil) {
+					return
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				yield("", err)
+				return
+			}
+		}
+	}
+}
+
+// DelPattern deletes the keys matching pattern with single-key DELs, which
+// valkey-go pipelines and routes to the slot of each key.
+func (v *ValkeyCache) DelPattern(ctx context.Context, pattern string) (int64, error) {
+	return delScanned(v.Scan(ctx, pattern), func(batch []string) (int64, error) {
+		cmds := make(valkey.Commands, len(batch))
+		for i, key := range batch {
+			cmds[i] = v.client.B().Del().Key(key).Build()
+		}
+
+		var deleted int64
+		for _, res := range v.client.DoMulti(ctx, cmds...) {
+			n, err := res.AsInt64()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+
+		return deleted, nil
+	})
+}
+
+// primaries returns the clients to scan: every primary of a cluster, or the
+// client itself.
+func (v *ValkeyCache) primaries(ctx context.Context) ([]valkey.Client, error) {
+	if v.client.Mode() != valkey.ClientModeCluster {
+		return []valkey.Client{v.client}, nil
+	}
+
+	var nodes []valkey.Client
+	for _, node := range v.client.Nodes() {
+		// Replicas hold copies of the keys of their primary.
+		role, err := node.Do(ctx, node.B().Role().Build()).ToArray()
+		if err != nil {
+			return nil, err
+		}
+		if len(role) > 0 {
+			if name, _ := role[0].ToString(); name == "master" {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+
+	return nodes, nil
+}
+
+// setCmd builds a SET with millisecond expiry; a ttl of zero or less never expires.
+func (v *ValkeyCache) setCmd(key string, value []byte, ttl time.Duration) valkey.Completed {
+	cmd := v.client.B().Set().Key(key).Value(string(value))
+	if ttl > 0 {
+		return cmd.Px(ttl).Build()
+	}
+
+	return cmd.Build()
+}
+
+// onInvalidations receives the RESP3 invalidation pushes of every connection.
+// valkey-go passes nil on a flush or when a connection is lost.
+func (v *ValkeyCache) onInvalidations(msgs []valkey.ValkeyMessage) {
+	if msgs == nil {
+		v.invalidations.notify(nil)
+		return
+	}
+
+	keys := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if key, err := msg.ToString(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	v.invalidations.notify(keys)
+}
+
+func (v *ValkeyCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	res, err := v.runScript(ctx, incrScript, []string{key}, delta, ttl.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res[0], nil
+}
+
+func (v *ValkeyCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return v.Incr(ctx, key, -delta, ttl)
+}
+
+func (v *ValkeyCache) CompareAndExpire(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	args := []string{string(value), strconv.FormatInt(ttl.Milliseconds(), 10)}
+	n, err := compareAndExpireScript.valkey.Exec(ctx, v.client, []string{key}, args).AsInt64()
+	return n == 1, err
+}
+
+func (v *ValkeyCache) CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error) {
+	n, err := compareAndDeleteScript.valkey.Exec(ctx, v.client, []string{key}, []string{string(value)}).AsInt64()
+	return n == 1, err
+}
+
+func (v *ValkeyCache) runScript(ctx context.Context, s *script, keys []string, args ...int64) ([]int64, error) {
+	argv := make([]string, len(args))
+	for i, arg := range args {
+		argv[i] = strconv.FormatInt(arg, 10)
+	}
+
+	return s.valkey.Exec(ctx, v.client, keys, argv).AsIntSlice()
+}
+
+// doWithTTL runs cmd on key, in a transaction setting the expiry of key when
+// ttl is positive.
+func (v *ValkeyCache) doWithTTL(ctx context.Context, key string, cmd valkey.Completed, ttl time.Duration) (valkey.ValkeyMessage, error) {
+	if ttl <= 0 {
+		return v.client.Do(ctx, cmd).ToMessage()
+	}
+
+	resps := v.client.DoMulti(ctx,
+		v.client.B().Multi().Build(),
+		cmd,
+		v.client.B().Pexpire().Key(key).Milliseconds(ttl.Milliseconds()).Build(),
+		v.client.B().Exec().Build(),
+	)
+	results, err := resps[len(resps)-1].ToArray()
+	if err != nil {
+		return valkey.ValkeyMessage{}, err
+	}
+	if len(results) == 0 {
+		return valkey.ValkeyMessage{}, errors.New("cache: empty transaction result")
+	}
+
+	return results[0], results[0].Error()
+}
+
+func (v *ValkeyCache) HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) error {
+	cmd := v.client.B().Hset().Key(key).FieldValue()
+	for field, value := range fields {
+		cmd = cmd.FieldValue(field, valkey.BinaryString(value))
+	}
+
+	_, err := v.doWithTTL(ctx, key, cmd.Build(), ttl)
+	return err
+}
+
+func (v *ValkeyCache) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	val, err := v.client.Do(ctx, v.client.B().Hget().Key(key).Field(field).Build()).AsBytes()
+	if valkey.IsValkeyNil(err) {
+		return nil, ErrNotFound
+	}
+
+	return val, err
+}
+
+func (v *ValkeyCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	values, err := v.client.Do(ctx, v.client.B().Hgetall().Key(key).Build()).AsStrMap()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string][]byte, len(values))
+	for field, value := range values {
+		fields[field] = []byte(value)
+	}
+
+	return fields, nil
+}
+
+func (v *ValkeyCache) HDel(ctx context.Context, key string, fields ...string) error {
+	return v.client.Do(ctx, v.client.B().Hdel().Key(key).Field(fields...).Build()).Error()
+}
+
+func (v *ValkeyCache) SAdd(ctx context.Context, key string, members [][]byte, ttl time.Duration) (int64, error) {
+	msg, err := v.doWithTTL(ctx, key, v.client.B().Sadd().Key(key).Member(binaryStrings(members)...).Build(), ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	return msg.AsInt64()
+}
+
+func (v *ValkeyCache) SRem(ctx context.Context, key string, members [][]byte) (int64, error) {
+	return v.client.Do(ctx, v.client.B().Srem().Key(key).Member(binaryStrings(members)...).Build()).AsInt64()
+}
+
+func (v *ValkeyCache) SMembers(ctx context.Context, key string) ([][]byte, error) {
+	values, err := v.client.Do(ctx, v.client.B().Smembers().Key(key).Build()).AsStrSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	return byteSlices(values), nil
+}
+
+func (v *ValkeyCache) SIsMember(ctx context.Context, key string, member []byte) (bool, error) {
+	return v.client.Do(ctx, v.client.B().Sismember().Key(key).Member(valkey.BinaryString(member)).Build()).AsBool()
+}
+
+func (v *ValkeyCache) ZAdd(ctx context.Context, key string, members []Scored[[]byte], ttl time.Duration) (int64, error) {
+	cmd := v.client.B().Zadd().Key(key).ScoreMember()
+	for _, m := range members {
+		cmd = cmd.ScoreMember(m.Score, valkey.BinaryString(m.Member))
+	}
+
+	msg, err := v.doWithTTL(ctx, key, cmd.Build(), ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	return msg.AsInt64()
+}
+
+func (v *ValkeyCache) ZIncrBy(ctx context.Context, key string, member []byte, delta float64) (float64, error) {
+	cmd := v.client.B().Zincrby().Key(key).Increment(delta).Member(valkey.BinaryString(member)).Build()
+	return v.client.Do(ctx, cmd).AsFloat64()
+}
+
+func (v *ValkeyCache) ZScore(ctx context.Context, key string, member []byte) (float64, error) {
+	score, err := v.client.Do(ctx, v.client.B().Zscore().Key(key).Member(valkey.BinaryString(member)).Build()).AsFloat64()
+	if valkey.IsValkeyNil(err) {
+		return 0, ErrNotFound
+	}
+
+	return score, err
+}
+
+func (v *ValkeyCache) ZRem(ctx context.Context, key string, members [][]byte) (int64, error) {
+	return v.client.Do(ctx, v.client.B().Zrem().Key(key).Member(binaryStrings(members)...).Build()).AsInt64()
+}
+
+func (v *ValkeyCache) ZRangeByScore(ctx context.Context, key string, min, max float64, offset, count int64, rev bool) ([]Scored[[]byte], error) {
+	args := []string{formatScore(min), formatScore(max), "BYSCORE"}
+	if rev {
+		args = []string{formatScore(max), formatScore(min), "BYSCORE", "REV"}
+	}
+	if offset > 0 || count > 0 {
+		if count == 0 {
+			count = -1
+		}
+		args = append(args, "LIMIT", strconv.FormatInt(offset, 10), strconv.FormatInt(count, 10))
+	}
+
+	return v.zrange(ctx, key, args)
+}
+
+func (v *ValkeyCache) ZRangeByRank(ctx context.Context, key string, start, stop int64, rev bool) ([]Scored[[]byte], error) {
+	args := []string{strconv.FormatInt(start, 10), strconv.FormatInt(stop, 10)}
+	if rev {
+		args = append(args, "REV")
+	}
+
+	return v.zrange(ctx, key, args)
+}
+
+// zrange runs ZRANGE with args, which combine options the command builder
+// only offers as separate chains.
+func (v *ValkeyCache) zrange(ctx context.Context, key string, args []string) ([]Scored[[]byte], error) {
+	cmd := v.client.B().Arbitrary("ZRANGE").Keys(key).Args(append(args, "WITHSCORES")...).ReadOnly()
+	zs, err := v.client.Do(ctx, cmd).AsZScores()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]Scored[[]byte], len(zs))
+	for i, z := range zs {
+		members[i] = Scored[[]byte]{Member: []byte(z.Member), Score: z.Score}
+	}
+
+	return members, nil
+}
+
+func binaryStrings(values [][]byte) []string {
+	out := make([]string, len(values))
+	for i, value := range values {
+		out[i] = valkey.BinaryString(value)
+	}
+
+	return out
+}
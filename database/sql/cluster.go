@@ -0,0 +1,261 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancer selects how a Cluster spreads reads over its replicas.
+type Balancer int
+
+const (
+	// RoundRobin sends each read to the next healthy replica.
+	RoundRobin Balancer = iota
+	// LeastConnections sends each read to the healthy replica with the
+	// fewest connections in use.
+	LeastConnections
+)
+
+type primaryKey struct{}
+
+// ForcePrimary returns a context whose reads go to the primary, for reads
+// that must see a write the replicas may not have replicated yet.
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Cluster routes writes and transactions to a primary and reads to its
+// replicas. Replicas failing a health check or a read with a connection
+// error are ejected until a health check succeeds again; reads fall back to
+// the primary when no replica is healthy.
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*replica
+	balancer Balancer
+	next     atomic.Uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// NewCluster opens connection pools to the primary, configured by the
+// options like New, and to each replica set with WithReplica. The replicas
+// share the credentials, database and pool settings of the primary. Only
+// the primary has to be reachable: unreachable replicas start ejected.
+func NewCluster(driver Driver, opts ...Option) (*Cluster, error) {
+	db := newDB(opts)
+
+	primary, err := db.open(driver)
+	if err != nil {
+		return nil, err
+	}
+	if err := primary.Ping(); err != nil {
+		_ = primary.Close()
+		return nil, err
+	}
+
+	replicas := make([]*sql.DB, 0, len(db.replicas))
+	for _, addr := range db.replicas {
+		r := *db
+		r.host, r.port, r.replicas = addr.host, addr.port, nil
+
+		conn, err := r.open(driver)
+		if err != nil {
+			_ = primary.Close()
+			for _, conn := range replicas {
+				_ = conn.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, conn)
+	}
+
+	return newCluster(primary, replicas, db.balancer, db.healthInterval), nil
+}
+
+func newCluster(primary *sql.DB, replicas []*sql.DB, balancer Balancer, healthInterval time.Duration) *Cluster {
+	c := &Cluster{primary: primary, balancer: balancer, stop: make(chan struct{})}
+	for _, conn := range replicas {
+		c.replicas = append(c.replicas, &replica{db: conn})
+	}
+	c.check(healthInterval)
+
+	if healthInterval > 0 && len(c.replicas) > 0 {
+		c.wg.Add(1)
+		go c.healthCheck(healthInterval)
+	}
+
+	return c
+}
+
+// healthCheck pings every replica each interval until the cluster is closed.
+func (c *Cluster) healthCheck(interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.check(interval)
+		}
+	}
+}
+
+func (c *Cluster) check(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range c.replicas {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			r.healthy.Store(r.db.PingContext(ctx) == nil)
+		})
+	}
+	wg.Wait()
+}
+
+// Primary returns the connection pool of the primary.
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// Replica returns the connection pool reads with ctx would use: a healthy
+// replica, or the primary if there is none or ctx comes from ForcePrimary.
+func (c *Cluster) Replica(ctx context.Context) *sql.DB {
+	if r := c.pick(ctx); r != nil {
+		return r.db
+	}
+
+	return c.primary
+}
+
+func (c *Cluster) pick(ctx context.Context) *replica {
+	if force, _ := ctx.Value(primaryKey{}).(bool); force || len(c.replicas) == 0 {
+		return nil
+	}
+
+	start := int(c.next.Add(1) % uint64(len(c.replicas)))
+	var best *replica
+	bestInUse := 0
+	for i := range c.replicas {
+		r := c.replicas[(start+i)%len(c.replicas)]
+		if !r.healthy.Load() {
+			continue
+		}
+		if c.balancer == RoundRobin {
+			return r
+		}
+		if inUse := r.db.Stats().InUse; best == nil || inUse < bestInUse {
+			best, bestInUse = r, inUse
+		}
+	}
+
+	return best
+}
+
+// QueryContext runs a query on a replica. If the replica fails with a
+// connection error, it is ejected and the query runs on the primary.
+func (c *Cluster) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if r := c.pick(ctx); r != nil {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if !c.failed(r, err) {
+			return rows, err
+		}
+	}
+
+	return c.primary.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query returning at most one row on a replica, like
+// QueryContext. Only errors reported before Scan fail over: a connection
+// breaking during Scan is returned by it and does not eject the replica.
+func (c *Cluster) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if r := c.pick(ctx); r != nil {
+		row := r.db.QueryRowContext(ctx, query, args...)
+		if !c.failed(r, row.Err()) {
+			return row
+		}
+	}
+
+	return c.primary.QueryRowContext(ctx, query, args...)
+}
+
+// failed ejects r and reports true if err is a connection error.
+func (c *Cluster) failed(r *replica, err error) bool {
+	if err == nil || !IsConnectionError(err) {
+		return false
+	}
+
+	r.healthy.Store(false)
+	return true
+}
+
+// ExecContext runs a statement on the primary.
+func (c *Cluster) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.primary.ExecContext(ctx, query, args...)
+}
+
+// BeginTx starts a transaction on the primary.
+func (c *Cluster) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.primary.BeginTx(ctx, opts)
+}
+
+// PingContext pings the primary.
+func (c *Cluster) PingContext(ctx context.Context) error {
+	return c.primary.PingContext(ctx)
+}
+
+// Close stops the health checks and closes every connection pool. Calling it
+// again only closes the pools again.
+func (c *Cluster) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.wg.Wait()
+
+	errs := []error{c.primary.Close()}
+	for _, r := range c.replicas {
+		errs = append(errs, r.db.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithReplica adds a read replica, used by NewCluster. Call it once per
+// replica.
+func WithReplica(host string, port int) Option {
+	return func(db *DB) {
+		db.replicas = append(db.replicas, replicaAddr{host: host, port: port})
+	}
+}
+
+// WithBalancer sets how NewCluster spreads reads over the replicas
+// (default: RoundRobin).
+func WithBalancer(balancer Balancer) Option {
+	return func(db *DB) {
+		db.balancer = balancer
+	}
+}
+
+// WithHealthCheck sets how often NewCluster pings the replicas, each ping
+// timing out after the same interval (default: 5s). Zero disables the
+// health checks, so ejected replicas never return.
+func WithHealthCheck(interval time.Duration) Option {
+	return func(db *DB) {
+		db.healthInterval = interval
+	}
+}
@@ -0,0 +1,157 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCluster returns a cluster of SQLite databases whose name table
+// holds "primary", "r0", "r1"...
+func newTestCluster(t *testing.T, replicas int, balancer Balancer) *Cluster {
+	t.Helper()
+	dir := t.TempDir()
+
+	open := func(name string) *sql.DB {
+		db, err := New(&SQLiteDriver{}, WithDatabase(filepath.Join(dir, name+".db")))
+		if err != nil {
+			t.Fatalf("failed to open sqlite: %v", err)
+		}
+		if _, err := db.Exec("CREATE TABLE name (name TEXT)"); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		if _, err := db.Exec("INSERT INTO name VALUES (?)", name); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+		return db
+	}
+
+	var dbs []*sql.DB
+	for i := range replicas {
+		dbs = append(dbs, open(fmt.Sprintf("r%d", i)))
+	}
+	c := newCluster(open("primary"), dbs, balancer, 0)
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+func readName(t *testing.T, ctx context.Context, c *Cluster) string {
+	t.Helper()
+	var name string
+	if err := c.QueryRowContext(ctx, "SELECT name FROM name").Scan(&name); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	return name
+}
+
+func TestCluster_Routing(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 2, RoundRobin)
+
+	seen := map[string]int{}
+	for range 4 {
+		seen[readName(t, ctx, c)]++
+	}
+	if seen["r0"] != 2 || seen["r1"] != 2 {
+		t.Errorf("expected reads spread over the replicas, got %v", seen)
+	}
+
+	rows, err := c.QueryContext(ctx, "SELECT name FROM name")
+	if err != nil {
+		t.Fatalf("QueryContext failed: %v", err)
+	}
+	_ = rows.Close()
+
+	if name := readName(t, ForcePrimary(ctx), c); name != "primary" {
+		t.Errorf("expected ForcePrimary to read the primary, got %s", name)
+	}
+
+	if _, err := c.ExecContext(ctx, "UPDATE name SET name = 'written'"); err != nil {
+		t.Fatalf("ExecContext failed: %v", err)
+	}
+	var name string
+	_ = c.Primary().QueryRow("SELECT name FROM name").Scan(&name)
+	if name != "written" {
+		t.Errorf("expected the write to go to the primary, got %s", name)
+	}
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	_ = tx.QueryRow("SELECT name FROM name").Scan(&name)
+	_ = tx.Rollback()
+	if name != "written" {
+		t.Errorf("expected the transaction to run on the primary, got %s", name)
+	}
+}
+
+func TestCluster_Ejection(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 2, RoundRobin)
+
+	_ = c.replicas[0].db.Close()
+	c.check(time.Second)
+	for range 3 {
+		if name := readName(t, ctx, c); name != "r1" {
+			t.Errorf("expected the failed replica to be ejected, got %s", name)
+		}
+	}
+
+	if c.failed(c.replicas[1], context.DeadlineExceeded) || !c.replicas[1].healthy.Load() {
+		t.Error("expected an expired deadline not to eject the replica")
+	}
+	if !c.failed(c.replicas[1], driver.ErrBadConn) {
+		t.Error("expected a connection error to eject the replica")
+	}
+	if name := readName(t, ctx, c); name != "primary" {
+		t.Errorf("expected reads to fall back to the primary, got %s", name)
+	}
+
+	c.check(time.Second)
+	if name := readName(t, ctx, c); name != "r1" {
+		t.Errorf("expected the replica to return after a health check, got %s", name)
+	}
+}
+
+func TestCluster_LeastConnections(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCluster(t, 2, LeastConnections)
+
+	conn, err := c.replicas[0].db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get a connection: %v", err)
+	}
+	defer conn.Close()
+
+	for range 3 {
+		if name := readName(t, ctx, c); name != "r1" {
+			t.Errorf("expected the idle replica, got %s", name)
+		}
+	}
+}
+
+func TestNewCluster(t *testing.T) {
+	mockDrv.failPing = false
+	c, err := NewCluster(&MockDriver{name: "mock"}, WithReplica("replica-1", 5432), WithReplica("replica-2", 5432),
+		WithBalancer(LeastConnections), WithHealthCheck(time.Hour))
+	if err != nil {
+		t.Fatalf("NewCluster failed: %v", err)
+	}
+	if len(c.replicas) != 2 || c.balancer != LeastConnections || !c.replicas[0].healthy.Load() {
+		t.Errorf("unexpected cluster %+v", c)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	_ = c.Close() // must not panic
+
+	if _, err := NewCluster(&MockDriver{name: "not-registered"}); err == nil {
+		t.Error("expected an error on a non-registered driver")
+	}
+}
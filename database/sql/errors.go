@@ -0,0 +1,181 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type errorKind int
+
+const (
+	unknownError errorKind = iota
+	uniqueViolation
+	foreignKeyViolation
+	notNullViolation
+	deadlock
+	connectionError
+)
+
+var (
+	// MySQL and SQL Server only name the constraint in their messages.
+	mysqlDuplicateKey = regexp.MustCompile("for key '([^']+)'")
+	mysqlConstraint   = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+	mysqlColumn       = regexp.MustCompile("(?:Column|Field) '([^']+)'")
+	mssqlConstraint   = regexp.MustCompile(`(?:constraint|unique index) ["']([^"']+)["']`)
+	mssqlColumn       = regexp.MustCompile(`into column '([^']+)'`)
+)
+
+// IsUniqueViolation reports whether err is a unique or primary key violation
+// and returns the name of the violated constraint or index when the driver
+// reports it. SQLite does not.
+func IsUniqueViolation(err error) (constraint string, ok bool) {
+	kind, name := classify(err)
+	return name, kind == uniqueViolation
+}
+
+// IsForeignKeyViolation reports whether err is a foreign key violation and
+// returns the name of the violated constraint when the driver reports it.
+// SQLite does not.
+func IsForeignKeyViolation(err error) (constraint string, ok bool) {
+	kind, name := classify(err)
+	return name, kind == foreignKeyViolation
+}
+
+// IsNotNullViolation reports whether err is a NULL written to a NOT NULL
+// column and returns the name of the column when the driver reports it.
+// SQLite does not.
+func IsNotNullViolation(err error) (column string, ok bool) {
+	kind, name := classify(err)
+	return name, kind == notNullViolation
+}
+
+// IsDeadlock reports whether err aborted a transaction to break a deadlock.
+func IsDeadlock(err error) bool {
+	kind, _ := classify(err)
+	return kind == deadlock
+}
+
+// IsConnectionError reports whether err comes from a broken or refused
+// connection rather than from the statement. A cancelled context or an
+// expired deadline is not a connection error.
+func IsConnectionError(err error) bool {
+	// context.DeadlineExceeded implements net.Error.
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	kind, _ := classify(err)
+	if kind == connectionError {
+		return true
+	}
+
+	var netErr net.Error
+	var connectErr *pgconn.ConnectError
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.As(err, &netErr) || errors.As(err, &connectErr)
+}
+
+// classify returns the kind of err and the constraint or column it names.
+func classify(err error) (errorKind, string) {
+	var pgErr *pgconn.PgError
+	var myErr *mysql.MySQLError
+	var msErr mssql.Error
+	var liteErr *sqlite.Error
+
+	switch {
+	case errors.As(err, &pgErr):
+		return classifyPostgres(pgErr)
+	case errors.As(err, &myErr):
+		return classifyMySQL(myErr)
+	case errors.As(err, &msErr):
+		return classifySQLServer(msErr)
+	case errors.As(err, &liteErr):
+		return classifySQLite(liteErr)
+	default:
+		return unknownError, ""
+	}
+}
+
+func classifyPostgres(err *pgconn.PgError) (errorKind, string) {
+	switch {
+	case err.Code == "23505":
+		return uniqueViolation, err.ConstraintName
+	case err.Code == "23503":
+		return foreignKeyViolation, err.ConstraintName
+	case err.Code == "23502":
+		return notNullViolation, err.ColumnName
+	case err.Code == "40P01":
+		return deadlock, ""
+	case strings.HasPrefix(err.Code, "08"), err.Code == "57P01", err.Code == "57P02", err.Code == "57P03":
+		// connection exceptions and server shutdowns
+		return connectionError, ""
+	default:
+		return unknownError, ""
+	}
+}
+
+func classifyMySQL(err *mysql.MySQLError) (errorKind, string) {
+	switch err.Number {
+	case 1062:
+		return uniqueViolation, submatch(mysqlDuplicateKey, err.Message)
+	case 1451, 1452:
+		return foreignKeyViolation, submatch(mysqlConstraint, err.Message)
+	case 1048, 1364:
+		return notNullViolation, submatch(mysqlColumn, err.Message)
+	case 1213:
+		return deadlock, ""
+	default:
+		return unknownError, ""
+	}
+}
+
+func classifySQLServer(err mssql.Error) (errorKind, string) {
+	switch err.Number {
+	case 2627, 2601:
+		return uniqueViolation, submatch(mssqlConstraint, err.Message)
+	case 547:
+		// 547 is also reported for CHECK constraints.
+		if strings.Contains(err.Message, "FOREIGN KEY") || strings.Contains(err.Message, "REFERENCE") {
+			return foreignKeyViolation, submatch(mssqlConstraint, err.Message)
+		}
+		return unknownError, ""
+	case 515:
+		return notNullViolation, submatch(mssqlColumn, err.Message)
+	case 1205:
+		return deadlock, ""
+	default:
+		return unknownError, ""
+	}
+}
+
+func classifySQLite(err *sqlite.Error) (errorKind, string) {
+	switch err.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return uniqueViolation, ""
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return foreignKeyViolation, ""
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		return notNullViolation, ""
+	default:
+		return unknownError, ""
+	}
+}
+
+// submatch returns the first group of re in s, or "" if it does not match.
+func submatch(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
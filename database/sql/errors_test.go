@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func TestErrorPredicates(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind errorKind
+		want string
+	}{
+		{"postgres unique", &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}, uniqueViolation, "users_email_key"},
+		{"postgres foreign key", &pgconn.PgError{Code: "23503", ConstraintName: "posts_user_id_fkey"}, foreignKeyViolation, "posts_user_id_fkey"},
+		{"postgres not null", &pgconn.PgError{Code: "23502", ColumnName: "name"}, notNullViolation, "name"},
+		{"postgres deadlock", &pgconn.PgError{Code: "40P01"}, deadlock, ""},
+		{"postgres admin shutdown", &pgconn.PgError{Code: "57P01"}, connectionError, ""},
+		{"mysql unique", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'users.email'"}, uniqueViolation, "users.email"},
+		{"mysql foreign key", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`app`.`posts`, CONSTRAINT `posts_user_fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"}, foreignKeyViolation, "posts_user_fk"},
+		{"mysql not null", &mysql.MySQLError{Number: 1048, Message: "Column 'name' cannot be null"}, notNullViolation, "name"},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213}, deadlock, ""},
+		{"sqlserver unique", mssql.Error{Number: 2627, Message: "Violation of UNIQUE KEY constraint 'UQ_users_email'. Cannot insert duplicate key in object 'dbo.users'."}, uniqueViolation, "UQ_users_email"},
+		{"sqlserver unique index", mssql.Error{Number: 2601, Message: "Cannot insert duplicate key row in object 'dbo.users' with unique index 'IX_users_email'."}, uniqueViolation, "IX_users_email"},
+		{"sqlserver foreign key", mssql.Error{Number: 547, Message: `The INSERT statement conflicted with the FOREIGN KEY constraint "FK_posts_users".`}, foreignKeyViolation, "FK_posts_users"},
+		{"sqlserver check", mssql.Error{Number: 547, Message: `The INSERT statement conflicted with the CHECK constraint "CK_age".`}, unknownError, ""},
+		{"sqlserver not null", mssql.Error{Number: 515, Message: "Cannot insert the value NULL into column 'name', table 'app.dbo.users'; column does not allow nulls."}, notNullViolation, "name"},
+		{"sqlserver deadlock", mssql.Error{Number: 1205}, deadlock, ""},
+		{"other", errors.New("other"), unknownError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", tt.err)
+
+			name, unique := IsUniqueViolation(err)
+			_, foreignKey := IsForeignKeyViolation(err)
+			_, notNull := IsNotNullViolation(err)
+			if unique != (tt.kind == uniqueViolation) || foreignKey != (tt.kind == foreignKeyViolation) || notNull != (tt.kind == notNullViolation) {
+				t.Errorf("expected kind %d, got unique=%v foreign key=%v not null=%v", tt.kind, unique, foreignKey, notNull)
+			}
+			if IsDeadlock(err) != (tt.kind == deadlock) {
+				t.Errorf("expected IsDeadlock to be %v", tt.kind == deadlock)
+			}
+			if IsConnectionError(err) != (tt.kind == connectionError) {
+				t.Errorf("expected IsConnectionError to be %v", tt.kind == connectionError)
+			}
+			if _, got := classify(err); got != tt.want {
+				t.Errorf("expected name %q, got %q", tt.want, got)
+			}
+			if unique && name != tt.want {
+				t.Errorf("expected constraint %q, got %q", tt.want, name)
+			}
+		})
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	for _, err := range []error{
+		driver.ErrBadConn,
+		mysql.ErrInvalidConn,
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")},
+	} {
+		if !IsConnectionError(fmt.Errorf("wrapped: %w", err)) {
+			t.Errorf("expected %v to be a connection error", err)
+		}
+	}
+	for _, err := range []error{
+		nil,
+		context.Canceled,
+		context.DeadlineExceeded,
+		fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+		&net.OpError{Op: "dial", Err: context.Canceled},
+	} {
+		if IsConnectionError(err) {
+			t.Errorf("expected %v not to be a connection error", err)
+		}
+	}
+}
+
+func TestErrorPredicates_SQLite(t *testing.T) {
+	db, err := New(&SQLiteDriver{}, WithDatabase(filepath.Join(t.TempDir(), "errors.db")), WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"PRAGMA foreign_keys = ON",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE)",
+		"CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id))",
+		"INSERT INTO users (id, email) VALUES (1, 'a@b.c')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	_, err = db.Exec("INSERT INTO users (id, email) VALUES (2, 'a@b.c')")
+	if _, ok := IsUniqueViolation(err); !ok {
+		t.Errorf("expected a unique violation, got %v", err)
+	}
+	_, err = db.Exec("INSERT INTO users (id, email) VALUES (1, 'd@e.f')")
+	if _, ok := IsUniqueViolation(err); !ok {
+		t.Errorf("expected a primary key violation, got %v", err)
+	}
+	_, err = db.Exec("INSERT INTO users (id, email) VALUES (3, NULL)")
+	if _, ok := IsNotNullViolation(err); !ok {
+		t.Errorf("expected a not null violation, got %v", err)
+	}
+	_, err = db.Exec("INSERT INTO posts (id, user_id) VALUES (1, 42)")
+	if _, ok := IsForeignKeyViolation(err); !ok {
+		t.Errorf("expected a foreign key violation, got %v", err)
+	}
+}
@@ -0,0 +1,448 @@
+package sql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMigrationDrift is returned when an applied migration was changed or
+	// removed since it was applied.
+	ErrMigrationDrift = errors.New("migration drift")
+
+	// ErrNoDownMigration is returned by Down when a migration has no down file.
+	ErrNoDownMigration = errors.New("no down migration")
+)
+
+// Migration is a versioned schema change read from a pair of files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up
+}
+
+// MigrationStatus is a migration with the time it was applied, zero when it
+// is pending.
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// MigrateConfig defines the configuration of a Migrator.
+type MigrateConfig struct {
+	Dir       string        // directory of the migration files in the file system
+	Table     string        // name of the table tracking applied migrations
+	LockStale time.Duration // age after which a SQLite migration lock is taken over
+}
+
+// MigrateOption defines a functional option for configuring a Migrator.
+type MigrateOption func(*MigrateConfig)
+
+// migrationDialect is implemented by the drivers that can run migrations.
+type migrationDialect interface {
+	placeholder(n int) string
+	migrationsTable(table string) string
+	// lock takes a lock named name held by conn until unlock is called, so
+	// only one process migrates at a time. Dialects whose locks outlive a
+	// crashed holder take over locks older than stale.
+	lock(ctx context.Context, conn *sql.Conn, name string, stale time.Duration) (unlock func(context.Context) error, err error)
+}
+
+// statementSplitter is implemented by drivers that cannot run several
+// statements in a single Exec.
+type statementSplitter interface {
+	splitStatements(script string) []string
+}
+
+// Migrator applies and rolls back migrations on a database.
+type Migrator struct {
+	db         *sql.DB
+	driver     Driver
+	dialect    migrationDialect
+	cfg        *MigrateConfig
+	migrations []Migration
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// NewMigrator reads the migrations in fsys, usually an embed.FS, for db, a
+// connection opened by New with driver.
+func NewMigrator(db *sql.DB, driver Driver, fsys fs.FS, opts ...MigrateOption) (*Migrator, error) {
+	dialect, ok := driver.(migrationDialect)
+	if !ok {
+		return nil, fmt.Errorf("sql: %T cannot run migrations: %w", driver, errors.ErrUnsupported)
+	}
+
+	cfg := &MigrateConfig{
+		Dir:       ".",
+		Table:     "schema_migrations",
+		LockStale: 10 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	migrations, err := readMigrations(fsys, cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, driver: driver, dialect: dialect, cfg: cfg, migrations: migrations}, nil
+}
+
+// Migrate applies the pending migrations in fsys to db.
+func Migrate(ctx context.Context, db *sql.DB, driver Driver, fsys fs.FS, opts ...MigrateOption) error {
+	m, err := NewMigrator(db, driver, fsys, opts...)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.Up(ctx)
+	return err
+}
+
+func readMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("sql: migration %s: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("sql: migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+			sum := sha256.Sum256(data)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("sql: migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return compareVersions(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+func compareVersions(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Migrations returns the migrations read from the file system, oldest first.
+func (m *Migrator) Migrations() []Migration {
+	return slices.Clone(m.migrations)
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction with its record, and returns those it applied. It fails with
+// ErrMigrationDrift, before applying anything, if an applied migration was
+// changed or removed. MySQL commits DDL statements implicitly, so a failed
+// migration there may be partly applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		records, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkDrift(records); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := records[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration.Up, func(tx *sql.Tx) error {
+				query := fmt.Sprintf("INSERT INTO %s (version, name, checksum, applied_at) VALUES (%s, %s, %s, %s)",
+					m.cfg.Table, m.dialect.placeholder(1), m.dialect.placeholder(2), m.dialect.placeholder(3), m.dialect.placeholder(4))
+				_, err := tx.ExecContext(ctx, query, int64(migration.Version), migration.Name, migration.Checksum, time.Now().UnixMilli())
+				return err
+			}); err != nil {
+				return fmt.Errorf("sql: migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns those it rolled back. A negative steps rolls back every applied
+// migration.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		records, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkDrift(records); err != nil {
+			return err
+		}
+
+		for _, migration := range slices.Backward(m.migrations) {
+			if len(rolledBack) == steps {
+				break
+			}
+			if _, ok := records[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("sql: migration %d_%s: %w", migration.Version, migration.Name, ErrNoDownMigration)
+			}
+
+			if err := m.apply(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+				query := fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.cfg.Table, m.dialect.placeholder(1))
+				_, err := tx.ExecContext(ctx, query, int64(migration.Version))
+				return err
+			}); err != nil {
+				return fmt.Errorf("sql: migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status returns every migration with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, m.dialect.migrationsTable(m.cfg.Table)); err != nil {
+		return nil, err
+	}
+
+	records, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		status[i] = MigrationStatus{Migration: migration, AppliedAt: records[migration.Version].appliedAt}
+	}
+
+	return status, nil
+}
+
+// locked runs fn on a connection holding the migration lock, after creating
+// the migrations table.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := m.dialect.lock(ctx, conn, m.cfg.Table, m.cfg.LockStale)
+	if err != nil {
+		return fmt.Errorf("sql: migration lock: %w", err)
+	}
+	defer func() {
+		err = errors.Join(err, unlock(context.WithoutCancel(ctx)))
+	}()
+
+	if _, err := conn.ExecContext(ctx, m.dialect.migrationsTable(m.cfg.Table)); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// apply runs script and record in a transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	statements := []string{script}
+	if s, ok := m.driver.(statementSplitter); ok {
+		statements = s.splitStatements(script)
+	}
+	for _, stmt := range statements {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type migrationRecord struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[uint64]migrationRecord, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, checksum, applied_at FROM %s", m.cfg.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(map[uint64]migrationRecord)
+	for rows.Next() {
+		var version, appliedAt int64
+		var checksum string
+		if err := rows.Scan(&version, &checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+		records[uint64(version)] = migrationRecord{checksum: checksum, appliedAt: time.UnixMilli(appliedAt)}
+	}
+
+	return records, rows.Err()
+}
+
+// checkDrift compares the applied migrations with the files.
+func (m *Migrator) checkDrift(records map[uint64]migrationRecord) error {
+	var errs []error
+	for version, record := range records {
+		i := slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == version })
+		switch {
+		case i < 0:
+			errs = append(errs, fmt.Errorf("%w: migration %d was applied but its file is missing", ErrMigrationDrift, version))
+		case m.migrations[i].Checksum != record.checksum:
+			errs = append(errs, fmt.Errorf("%w: migration %d_%s changed since it was applied", ErrMigrationDrift, version, m.migrations[i].Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// splitSQL splits script into statements at semicolons outside of quotes
+// and comments.
+func splitSQL(script string) []string {
+	var statements []string
+	start := 0
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(script) && script[i] != c; i++ {
+				if script[i] == '\\' {
+					i++
+				}
+			}
+		case c == '-' && strings.HasPrefix(script[i:], "--"), c == '#':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case c == ';':
+			statements = append(statements, script[start:i])
+			start = i + 1
+		}
+	}
+
+	if rest := script[min(start, len(script)):]; strings.TrimSpace(rest) != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// WithMigrationsDir sets the directory of the migration files in the file
+// system (default: ".").
+func WithMigrationsDir(dir string) MigrateOption {
+	return func(cfg *MigrateConfig) {
+		cfg.Dir = dir
+	}
+}
+
+// WithMigrationsTable sets the name of the table tracking applied migrations
+// (default: "schema_migrations").
+func WithMigrationsTable(table string) MigrateOption {
+	return func(cfg *MigrateConfig) {
+		cfg.Table = table
+	}
+}
+
+// WithLockStale sets how old the SQLite migration lock must be before another
+// process assumes its holder crashed and takes it over (default: 10 minutes).
+// The holder does not renew the lock, so it must be longer than a whole run of
+// Up or Down, or a second process may migrate at the same time. Other drivers
+// release their locks when the holder disconnects and ignore it.
+func WithLockStale(d time.Duration) MigrateOption {
+	return func(cfg *MigrateConfig) {
+		cfg.LockStale = d
+	}
+}
@@ -0,0 +1,186 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"migrations/001_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);\nCREATE INDEX users_name ON users (name);")},
+		"migrations/001_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations/002_posts.up.sql":   {Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER);")},
+		"migrations/002_posts.down.sql": {Data: []byte("DROP TABLE posts;")},
+		"migrations/README.md":          {Data: []byte("ignored")},
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	driver := &SQLiteDriver{}
+	db, err := New(driver, WithDatabase(filepath.Join(t.TempDir(), "migrate.db")), WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	fsys := testMigrations()
+	m, err := NewMigrator(db, driver, fsys, WithMigrationsDir("migrations"))
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("expected 2 migrations applied, got %d (%v)", len(applied), err)
+	}
+	if _, err := db.Exec("INSERT INTO posts (id, user_id) VALUES (1, 1)"); err != nil {
+		t.Errorf("expected the posts table to exist: %v", err)
+	}
+
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("expected nothing to apply, got %d (%v)", len(applied), err)
+	}
+
+	var locks int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations_lock").Scan(&locks); err != nil || locks != 0 {
+		t.Errorf("expected the lock to be released, got %d rows (%v)", locks, err)
+	}
+
+	rolledBack, err := m.Down(ctx, 1)
+	if err != nil || len(rolledBack) != 1 || rolledBack[0].Version != 2 {
+		t.Fatalf("expected migration 2 rolled back, got %v (%v)", rolledBack, err)
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status) != 2 || status[0].AppliedAt.IsZero() || !status[1].AppliedAt.IsZero() {
+		t.Errorf("expected only migration 1 applied, got %+v", status)
+	}
+
+	// Changing an applied migration is drift.
+	fsys["migrations/001_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")}
+	m, _ = NewMigrator(db, driver, fsys, WithMigrationsDir("migrations"))
+	if _, err := m.Up(ctx); !errors.Is(err, ErrMigrationDrift) {
+		t.Errorf("expected ErrMigrationDrift for a changed file, got %v", err)
+	}
+
+	// So is removing it.
+	delete(fsys, "migrations/001_users.up.sql")
+	delete(fsys, "migrations/001_users.down.sql")
+	m, _ = NewMigrator(db, driver, fsys, WithMigrationsDir("migrations"))
+	if _, err := m.Up(ctx); !errors.Is(err, ErrMigrationDrift) {
+		t.Errorf("expected ErrMigrationDrift for a missing file, got %v", err)
+	}
+}
+
+func TestMigrator_FailedMigration(t *testing.T) {
+	ctx := context.Background()
+	driver := &SQLiteDriver{}
+	db, err := New(driver, WithDatabase(filepath.Join(t.TempDir(), "migrate.db")), WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"001_ok.up.sql":     {Data: []byte("CREATE TABLE ok (id INTEGER);")},
+		"002_broken.up.sql": {Data: []byte("CREATE TABLE broken (id INTEGER); NOT SQL;")},
+	}
+	if err := Migrate(ctx, db, driver, fsys); err == nil {
+		t.Fatal("expected the broken migration to fail")
+	}
+
+	var versions int
+	_ = db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&versions)
+	if versions != 1 {
+		t.Errorf("expected only the first migration recorded, got %d", versions)
+	}
+	if _, err := db.Exec("SELECT * FROM broken"); err == nil {
+		t.Error("expected the failed migration to be rolled back")
+	}
+}
+
+func TestNewMigrator_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"two names", fstest.MapFS{
+			"001_a.up.sql": {Data: []byte("SELECT 1")},
+			"001_b.up.sql": {Data: []byte("SELECT 1")},
+		}},
+		{"no up file", fstest.MapFS{
+			"001_a.down.sql": {Data: []byte("SELECT 1")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMigrator(nil, &SQLiteDriver{}, tt.fsys); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := NewMigrator(nil, &MockDriver{}, fstest.MapFS{}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for an unknown driver, got %v", err)
+	}
+}
+
+func TestSplitSQL(t *testing.T) {
+	script := `CREATE TABLE a (s TEXT DEFAULT 'x;y'); -- a comment; here
+/* block; comment */ INSERT INTO a VALUES ("q;\"r");
+# mysql comment;
+UPDATE a SET s = ` + "`;`" + `;
+`
+	got := splitSQL(script)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 statements, got %d: %q", len(got), got)
+	}
+	if !slices.ContainsFunc(got, func(s string) bool { return s == "CREATE TABLE a (s TEXT DEFAULT 'x;y')" }) {
+		t.Errorf("expected the quoted semicolon to be kept, got %q", got)
+	}
+}
+
+func TestMigrator_LockStale(t *testing.T) {
+	ctx := context.Background()
+	driver := &SQLiteDriver{}
+	db, err := New(driver, WithDatabase(filepath.Join(t.TempDir(), "migrate.db")), WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	// A lock left a minute ago by a crashed process.
+	if _, err := db.Exec("CREATE TABLE schema_migrations_lock (id INTEGER PRIMARY KEY, locked_at INTEGER NOT NULL)"); err != nil {
+		t.Fatalf("failed to create the lock table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", time.Now().Add(-time.Minute).UnixMilli()); err != nil {
+		t.Fatalf("failed to insert the lock: %v", err)
+	}
+
+	m, _ := NewMigrator(db, driver, testMigrations(), WithMigrationsDir("migrations"))
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if _, err := m.Up(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait for a lock younger than 10 minutes, got %v", err)
+	}
+
+	m, _ = NewMigrator(db, driver, testMigrations(), WithMigrationsDir("migrations"), WithLockStale(30*time.Second))
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 2 {
+		t.Fatalf("expected the stale lock taken over and 2 migrations applied, got %d (%v)", len(applied), err)
+	}
+
+	// A negative number of steps rolls back everything.
+	if rolledBack, err := m.Down(ctx, -1); err != nil || len(rolledBack) != 2 {
+		t.Errorf("expected 2 migrations rolled back, got %d (%v)", len(rolledBack), err)
+	}
+}
@@ -1,11 +1,20 @@
 package sql
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type MySQLDriver struct {
@@ -16,9 +25,184 @@ func NewMySQL(opts ...Option) (*sql.DB, error) {
 }
 
 func (driver *MySQLDriver) DSN(db *DB) string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=%s", db.username, db.password, db.host, db.port, db.database, url.QueryEscape(db.timezone))
+	query := url.Values{}
+	query.Set("parseTime", "true")
+	query.Set("loc", db.timezone)
+	if tls := mysqlTLS(db); tls != "" {
+		query.Set("tls", tls)
+	}
+	if db.applicationName != "" {
+		query.Set("connectionAttributes", "program_name:"+db.applicationName)
+	}
+	if db.connectTimeout > 0 {
+		query.Set("timeout", db.connectTimeout.String())
+	}
+
+	cfg := mysql.NewConfig()
+	cfg.User = db.username
+	cfg.Passwd = db.password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", db.host, db.port)
+	cfg.DBName = db.database
+
+	return cfg.FormatDSN() + "?" + db.query(query, "parseTime", "loc")
+}
+
+// mysqlTLS returns the tls parameter: one of the modes of the driver, or the
+// name of a configuration registered by prepare for certificates, server
+// names and verification without host name.
+func mysqlTLS(db *DB) string {
+	custom := db.tlsRootCA != "" || db.tlsCert != "" || db.tlsServerName != ""
+	switch mode := db.tls(); {
+	case mode == TLSDisable:
+		return "false"
+	case mode == TLSPrefer:
+		return "preferred"
+	case mode == TLSRequire && db.tlsCert == "":
+		return "skip-verify"
+	case mode == TLSVerifyFull && !custom:
+		return "true"
+	case mode == "":
+		return ""
+	default:
+		h := fnv.New64a()
+		for _, s := range []string{string(mode), db.host, db.tlsRootCA, db.tlsCert, db.tlsKey, db.tlsServerName} {
+			_, _ = h.Write([]byte(s + "\x00"))
+		}
+		return "toolkit-" + strconv.FormatUint(h.Sum64(), 16)
+	}
+}
+
+// prepare registers the TLS configuration named by mysqlTLS, if any.
+func (driver *MySQLDriver) prepare(db *DB) error {
+	name := mysqlTLS(db)
+	if !strings.HasPrefix(name, "toolkit-") {
+		return nil
+	}
+
+	cfg := &tls.Config{ServerName: db.host}
+	if db.tlsServerName != "" {
+		cfg.ServerName = db.tlsServerName
+	}
+	if db.tlsRootCA != "" {
+		pem, err := os.ReadFile(db.tlsRootCA)
+		if err != nil {
+			return err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("sql: no certificate in %s", db.tlsRootCA)
+		}
+	}
+	if db.tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(db.tlsCert, db.tlsKey)
+		if err != nil {
+			return err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch db.tls() {
+	case TLSRequire:
+		cfg.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// Verify the chain but not the name, which crypto/tls cannot do alone.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, cfg.RootCAs)
+		}
+	}
+
+	return mysql.RegisterTLSConfig(name, cfg)
+}
+
+// verifyChain verifies the certificate chain sent by a server against roots,
+// or the system pool if nil.
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("sql: no server certificate")
+	}
+
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	var leaf *x509.Certificate
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			leaf = cert
+		} else {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+
+	_, err := leaf.Verify(opts)
+	return err
 }
 
 func (driver *MySQLDriver) Name() string {
 	return "mysql"
 }
+
+func (driver *MySQLDriver) placeholder(int) string {
+	return "?"
+}
+
+func (driver *MySQLDriver) migrationsTable(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at BIGINT NOT NULL
+)`, table)
+}
+
+// lock takes a named lock with GET_LOCK, waiting as long as needed. Lock
+// names are server-wide, so name is prefixed with the current database.
+func (driver *MySQLDriver) lock(ctx context.Context, conn *sql.Conn, name string, _ time.Duration) (func(context.Context) error, error) {
+	var database sql.NullString
+	if err := conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&database); err != nil {
+		return nil, err
+	}
+	name = mysqlLockName(database.String, name)
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&acquired); err != nil {
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		return nil, errors.New("GET_LOCK failed")
+	}
+
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+		return err
+	}, nil
+}
+
+// mysqlLockName returns the name of the lock on name in database, hashed
+// when longer than the 64 characters GET_LOCK accepts.
+func mysqlLockName(database, name string) string {
+	full := database + "." + name
+	if len(full) <= 64 {
+		return full
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(full))
+	return name[:min(len(name), 47)] + "." + strconv.FormatUint(h.Sum64(), 16)
+}
+
+// splitStatements splits migrations into statements, as the DSN does not
+// enable multiStatements.
+func (driver *MySQLDriver) splitStatements(script string) []string {
+	return splitSQL(script)
+}
+
+// Retryable reports whether err is a deadlock (1213) or a lock wait timeout
+// (1205).
+func (driver *MySQLDriver) Retryable(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && (myErr.Number == 1213 || myErr.Number == 1205)
+}
@@ -1,7 +1,20 @@
 package sql
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestMySQLDriver(t *testing.T) {
@@ -24,3 +37,140 @@ func TestMySQLDriver(t *testing.T) {
 		t.Errorf("expected name mysql, got %s", name)
 	}
 }
+
+func TestMySQLDriver_Retryable(t *testing.T) {
+	driver := &MySQLDriver{}
+	for number, want := range map[uint16]bool{1213: true, 1205: true, 1062: false} {
+		if got := driver.Retryable(&mysql.MySQLError{Number: number}); got != want {
+			t.Errorf("number %d: expected %v, got %v", number, want, got)
+		}
+	}
+}
+
+// writeCA writes a self-signed CA certificate to a temporary file and
+// returns the path with the certificate and key.
+func writeCA(t *testing.T) (string, *x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	return path, cert, key
+}
+
+func TestMySQLDriver_TLS(t *testing.T) {
+	driver := &MySQLDriver{}
+	base := []Option{WithUsername("user"), WithPassword("pass"), WithHost("db.example.com"), WithPort(3306), WithDatabase("mydb"), WithTimezone("UTC")}
+
+	for mode, want := range map[TLSMode]string{TLSDisable: "false", TLSPrefer: "preferred", TLSRequire: "skip-verify", TLSVerifyFull: "true"} {
+		db := newDB(append(base, WithTLS(mode)))
+		if err := driver.prepare(db); err != nil {
+			t.Errorf("mode %s: prepare failed: %v", mode, err)
+		}
+		if dsn := driver.DSN(db); !strings.Contains(dsn, "&tls="+want) {
+			t.Errorf("mode %s: expected tls=%s in %s", mode, want, dsn)
+		}
+	}
+
+	db := newDB(append(base, WithApplicationName("billing"), WithConnectTimeout(5*time.Second)))
+	expectedDSN := "user:pass@tcp(db.example.com:3306)/mydb?parseTime=true&loc=UTC&connectionAttributes=program_name%3Abilling&timeout=5s"
+	if dsn := driver.DSN(db); dsn != expectedDSN {
+		t.Errorf("expected DSN %s, got %s", expectedDSN, dsn)
+	}
+
+	caFile, _, _ := writeCA(t)
+	db = newDB(append(base, WithTLS(TLSVerifyCA), WithTLSRootCA(caFile), WithTLSServerName("mysql.internal")))
+	if err := driver.prepare(db); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	cfg, err := mysql.ParseDSN(driver.DSN(db))
+	if err != nil {
+		t.Fatalf("failed to parse DSN: %v", err)
+	}
+	if cfg.TLS == nil || cfg.TLS.ServerName != "mysql.internal" || cfg.TLS.RootCAs == nil || cfg.TLS.VerifyPeerCertificate == nil {
+		t.Errorf("expected the registered TLS configuration, got %+v", cfg.TLS)
+	}
+
+	db = newDB(append(base, WithTLSRootCA(filepath.Join(t.TempDir(), "missing.pem"))))
+	if err := driver.prepare(db); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+}
+
+func TestMySQLDriver_Credentials(t *testing.T) {
+	driver := &MySQLDriver{}
+	db := newDB([]Option{
+		WithUsername("app"), WithPassword("p@ss:w/rd?#"), WithHost("localhost"), WithPort(3306), WithDatabase("my-db"),
+	})
+
+	cfg, err := mysql.ParseDSN(driver.DSN(db))
+	if err != nil {
+		t.Fatalf("failed to parse DSN: %v", err)
+	}
+	if cfg.User != "app" || cfg.Passwd != "p@ss:w/rd?#" || cfg.Addr != "localhost:3306" || cfg.DBName != "my-db" {
+		t.Errorf("expected the credentials to survive the DSN, got %s:%s@%s/%s", cfg.User, cfg.Passwd, cfg.Addr, cfg.DBName)
+	}
+}
+
+func TestMySQLLockName(t *testing.T) {
+	if got := mysqlLockName("billing", "schema_migrations"); got != "billing.schema_migrations" {
+		t.Errorf("expected billing.schema_migrations, got %s", got)
+	}
+	if a, b := mysqlLockName("billing", "schema_migrations"), mysqlLockName("orders", "schema_migrations"); a == b {
+		t.Errorf("expected databases to take different locks, got %s", a)
+	}
+
+	long := strings.Repeat("d", 64)
+	a, b := mysqlLockName(long+"1", "schema_migrations"), mysqlLockName(long+"2", "schema_migrations")
+	if len(a) > 64 || len(b) > 64 || a == b {
+		t.Errorf("expected distinct names of at most 64 characters, got %s and %s", a, b)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	_, ca, caKey := writeCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "some other name"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	if err := verifyChain([][]byte{der}, roots); err != nil {
+		t.Errorf("expected the chain to verify regardless of the name, got %v", err)
+	}
+	if err := verifyChain([][]byte{der}, x509.NewCertPool()); err == nil {
+		t.Error("expected an unknown CA to fail")
+	}
+	if err := verifyChain(nil, roots); err == nil {
+		t.Error("expected an error without certificates")
+	}
+}
@@ -1,10 +1,19 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 type PostgresDriver struct {
@@ -15,9 +24,104 @@ func NewPostgres(opts ...Option) (*sql.DB, error) {
 }
 
 func (driver *PostgresDriver) DSN(db *DB) string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable&timezone=%s", db.username, db.password, db.host, db.port, db.database, db.timezone)
+	query := url.Values{}
+	query.Set("sslmode", string(TLSDisable))
+	if mode := db.tls(); mode != "" {
+		query.Set("sslmode", string(mode))
+	}
+	query.Set("timezone", db.timezone)
+	if db.tlsRootCA != "" {
+		query.Set("sslrootcert", db.tlsRootCA)
+	}
+	if db.tlsCert != "" {
+		query.Set("sslcert", db.tlsCert)
+		query.Set("sslkey", db.tlsKey)
+	}
+	if db.applicationName != "" {
+		query.Set("application_name", db.applicationName)
+	}
+	if db.connectTimeout > 0 {
+		query.Set("connect_timeout", db.timeoutSeconds())
+	}
+
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(db.username, db.password),
+		Host:     fmt.Sprintf("%s:%d", db.host, db.port),
+		Path:     "/" + db.database,
+		RawQuery: db.query(query, "sslmode", "timezone"),
+	}
+
+	return u.String()
+}
+
+func (driver *PostgresDriver) connector(db *DB) (sqldriver.Connector, error) {
+	cfg, err := driver.connConfig(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return stdlib.GetConnector(*cfg), nil
+}
+
+// connConfig parses the DSN and sets the TLS server name, which libpq has no
+// parameter for, on every configuration pgx tries.
+func (driver *PostgresDriver) connConfig(db *DB) (*pgx.ConnConfig, error) {
+	cfg, err := pgx.ParseConfig(driver.DSN(db))
+	if err != nil {
+		return nil, err
+	}
+
+	if db.tlsServerName != "" {
+		if cfg.TLSConfig != nil {
+			cfg.TLSConfig.ServerName = db.tlsServerName
+		}
+		for _, fallback := range cfg.Fallbacks {
+			if fallback.TLSConfig != nil {
+				fallback.TLSConfig.ServerName = db.tlsServerName
+			}
+		}
+	}
+
+	return cfg, nil
 }
 
 func (driver *PostgresDriver) Name() string {
 	return "pgx"
 }
+
+func (driver *PostgresDriver) placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (driver *PostgresDriver) migrationsTable(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at BIGINT NOT NULL
+)`, table)
+}
+
+// lock takes a session advisory lock keyed by a hash of name.
+func (driver *PostgresDriver) lock(ctx context.Context, conn *sql.Conn, name string, _ time.Duration) (func(context.Context) error, error) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	key := int64(h.Sum64())
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+		return err
+	}, nil
+}
+
+// Retryable reports whether err is a serialization failure (40001) or a
+// deadlock (40P01).
+func (driver *PostgresDriver) Retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
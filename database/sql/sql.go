@@ -2,6 +2,13 @@ package sql
 
 import (
 	"database/sql"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/uptrace/opentelemetry-go-extra/otelsql"
@@ -23,9 +30,27 @@ type DB struct {
 	maxLifetime  time.Duration // maximum amount of time a connection may be reused
 	maxIdleTime  time.Duration // maximum amount of time a connection may be idle before being closed
 
+	tlsMode         TLSMode       // empty means the driver default, or TLSVerifyFull if a certificate or server name is set
+	tlsRootCA       string        // PEM file of the CA verifying the server
+	tlsCert         string        // PEM file of the client certificate
+	tlsKey          string        // PEM file of the client key
+	tlsServerName   string        // name verified in the server certificate instead of host
+	applicationName string        // reported to the server
+	connectTimeout  time.Duration // zero means the driver default
+	params          url.Values    // extra DSN parameters, overriding those set by the driver
+
+	replicas       []replicaAddr // read replicas, used by NewCluster
+	balancer       Balancer      // how NewCluster spreads reads over the replicas
+	healthInterval time.Duration // how often NewCluster pings the replicas; <= 0 disables health checks
+
 	conn *sql.DB
 }
 
+type replicaAddr struct {
+	host string
+	port int
+}
+
 type Driver interface {
 	DSN(db *DB) string
 	Name() string
@@ -33,22 +58,86 @@ type Driver interface {
 
 type Option func(*DB)
 
+// TLSMode selects whether and how connections are encrypted.
+type TLSMode string
+
+const (
+	TLSDisable    TLSMode = "disable"     // never encrypt
+	TLSPrefer     TLSMode = "prefer"      // encrypt if the server supports it
+	TLSRequire    TLSMode = "require"     // always encrypt, without verifying the server
+	TLSVerifyCA   TLSMode = "verify-ca"   // always encrypt and verify the server certificate
+	TLSVerifyFull TLSMode = "verify-full" // also verify the server name
+)
+
+// preparer is implemented by drivers that validate or register settings
+// before their DSN is built.
+type preparer interface {
+	prepare(db *DB) error
+}
+
+// connector is implemented by drivers that open connections through a
+// driver.Connector, to set what their DSN cannot express.
+type connector interface {
+	connector(db *DB) (sqldriver.Connector, error)
+}
+
+// New opens a connection pool to the database. Replicas set with WithReplica
+// are ignored: New connects to the primary only, NewCluster to all of them.
 func New(driver Driver, opts ...Option) (*sql.DB, error) {
+	conn, err := newDB(opts).open(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = conn.Ping(); err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func newDB(opts []Option) *DB {
 	db := &DB{
-		timezone:     "Local",
-		maxIdleCount: 10,
-		maxOpen:      100,
-		maxLifetime:  1 * time.Hour,
-		maxIdleTime:  1 * time.Minute,
+		timezone:       "Local",
+		maxIdleCount:   10,
+		maxOpen:        100,
+		maxLifetime:    1 * time.Hour,
+		maxIdleTime:    1 * time.Minute,
+		balancer:       RoundRobin,
+		healthInterval: 5 * time.Second,
 	}
 	for _, opt := range opts {
 		opt(db)
 	}
 
-	var err error
-	db.conn, err = otelsql.Open(driver.Name(), driver.DSN(db))
-	if err != nil {
-		return nil, err
+	return db
+}
+
+// open opens a connection pool without connecting.
+func (db *DB) open(driver Driver) (*sql.DB, error) {
+	switch db.tls() {
+	case "", TLSDisable, TLSPrefer, TLSRequire, TLSVerifyCA, TLSVerifyFull:
+	default:
+		return nil, fmt.Errorf("sql: unknown TLS mode %q", db.tlsMode)
+	}
+	if p, ok := driver.(preparer); ok {
+		if err := p.prepare(db); err != nil {
+			return nil, err
+		}
+	}
+
+	if c, ok := driver.(connector); ok {
+		conn, err := c.connector(db)
+		if err != nil {
+			return nil, err
+		}
+		db.conn = otelsql.OpenDB(conn)
+	} else {
+		var err error
+		db.conn, err = otelsql.Open(driver.Name(), driver.DSN(db))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	db.conn.SetMaxIdleConns(db.maxIdleCount)
@@ -57,11 +146,50 @@ func New(driver Driver, opts ...Option) (*sql.DB, error) {
 	db.conn.SetConnMaxIdleTime(db.maxIdleTime)
 	otelsql.ReportDBStatsMetrics(db.conn)
 
-	if err = db.conn.Ping(); err != nil {
-		return nil, err
+	return db.conn, nil
+}
+
+// tls returns the TLS mode, defaulting to TLSVerifyFull when a certificate
+// or server name is set.
+func (db *DB) tls() TLSMode {
+	if db.tlsMode == "" && (db.tlsRootCA != "" || db.tlsCert != "" || db.tlsServerName != "") {
+		return TLSVerifyFull
 	}
 
-	return db.conn, nil
+	return db.tlsMode
+}
+
+// query encodes params with the keys in first in that order, then the
+// others sorted, after applying the extra parameters.
+func (db *DB) query(params url.Values, first ...string) string {
+	for key, values := range db.params {
+		params[key] = values
+	}
+
+	var sb strings.Builder
+	write := func(key string) {
+		for _, value := range params[key] {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(key) + "=" + url.QueryEscape(value))
+		}
+	}
+	for _, key := range first {
+		write(key)
+	}
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		if !slices.Contains(first, key) {
+			write(key)
+		}
+	}
+
+	return sb.String()
+}
+
+// timeoutSeconds returns the connect timeout in whole seconds, rounded up.
+func (db *DB) timeoutSeconds() string {
+	return strconv.FormatInt(int64((db.connectTimeout+time.Second-1)/time.Second), 10)
 }
 
 func WithUsername(username string) Option {
@@ -130,6 +258,62 @@ func WithTimezone(timezone string) Option {
 	}
 }
 
+// WithTLS sets whether and how connections are encrypted. SQLite ignores it.
+func WithTLS(mode TLSMode) Option {
+	return func(db *DB) {
+		db.tlsMode = mode
+	}
+}
+
+// WithTLSRootCA sets the PEM file of the CA verifying the server, instead of
+// the system pool.
+func WithTLSRootCA(file string) Option {
+	return func(db *DB) {
+		db.tlsRootCA = file
+	}
+}
+
+// WithTLSClientCert sets the PEM files of the client certificate and key.
+// SQL Server does not support client certificates.
+func WithTLSClientCert(certFile, keyFile string) Option {
+	return func(db *DB) {
+		db.tlsCert = certFile
+		db.tlsKey = keyFile
+	}
+}
+
+// WithTLSServerName sets the name verified in the server certificate, when
+// it differs from the host.
+func WithTLSServerName(name string) Option {
+	return func(db *DB) {
+		db.tlsServerName = name
+	}
+}
+
+// WithApplicationName sets the name the server reports for the connections.
+func WithApplicationName(name string) Option {
+	return func(db *DB) {
+		db.applicationName = name
+	}
+}
+
+// WithConnectTimeout sets how long opening a connection may take.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(db *DB) {
+		db.connectTimeout = timeout
+	}
+}
+
+// WithParam adds a DSN parameter, overriding the one the driver sets if any.
+func WithParam(key, value string) Option {
+	return func(db *DB) {
+		if db.params == nil {
+			db.params = url.Values{}
+		}
+		db.params.Set(key, value)
+	}
+}
+
 func (db *DB) Close() error {
 	if db.conn == nil {
 		return nil
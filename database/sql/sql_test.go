@@ -180,3 +180,9 @@ func TestDB_Close(t *testing.T) {
 		t.Errorf("expected nil error on close, got %v", err)
 	}
 }
+
+func TestNew_UnknownTLSMode(t *testing.T) {
+	if _, err := New(&MockDriver{name: "mock"}, WithTLS("sometimes")); err == nil {
+		t.Fatal("expected error on an unknown TLS mode, got nil")
+	}
+}
@@ -1,11 +1,15 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/url"
+	"time"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 type SQLiteDriver struct {
@@ -16,9 +20,66 @@ func NewSQLite(opts ...Option) (*sql.DB, error) {
 }
 
 func (driver *SQLiteDriver) DSN(db *DB) string {
-	return fmt.Sprintf("%s?_loc=%s", db.database, url.QueryEscape(db.timezone))
+	return fmt.Sprintf("%s?%s", db.database, db.query(url.Values{"_loc": {db.timezone}}, "_loc"))
 }
 
 func (driver *SQLiteDriver) Name() string {
 	return "sqlite"
 }
+
+func (driver *SQLiteDriver) placeholder(int) string {
+	return "?"
+}
+
+func (driver *SQLiteDriver) migrationsTable(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`, table)
+}
+
+// lock inserts the single row of a <name>_lock table, polling until it can,
+// as SQLite has no advisory locks. A row older than stale is assumed to be
+// left by a crashed process and deleted.
+func (driver *SQLiteDriver) lock(ctx context.Context, conn *sql.Conn, name string, stale time.Duration) (func(context.Context) error, error) {
+	table := name + "_lock"
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INTEGER PRIMARY KEY, locked_at INTEGER NOT NULL)", table)); err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE locked_at < ?", table), now.Add(-stale).UnixMilli()); err != nil {
+			return nil, err
+		}
+
+		res, err := conn.ExecContext(ctx, fmt.Sprintf("INSERT OR IGNORE INTO %s (id, locked_at) VALUES (1, ?)", table), now.UnixMilli())
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = 1", table))
+		return err
+	}, nil
+}
+
+// Retryable reports whether err is SQLITE_BUSY, including its extended codes.
+func (driver *SQLiteDriver) Retryable(err error) bool {
+	var liteErr *sqlite.Error
+	return errors.As(err, &liteErr) && liteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+}
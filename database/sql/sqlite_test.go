@@ -1,6 +1,10 @@
 package sql
 
 import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
 	"testing"
 )
 
@@ -16,7 +20,51 @@ func TestSQLiteDriver(t *testing.T) {
 		t.Errorf("expected DSN %s, got %s", expectedDSN, dsn)
 	}
 
+	db.params = url.Values{"_pragma": {"busy_timeout(5000)"}}
+	expectedDSN = "test.db?_loc=UTC&_pragma=busy_timeout%285000%29"
+	if dsn := driver.DSN(db); dsn != expectedDSN {
+		t.Errorf("expected DSN %s, got %s", expectedDSN, dsn)
+	}
+
 	if name := driver.Name(); name != "sqlite" {
 		t.Errorf("expected name sqlite, got %s", name)
 	}
 }
+
+func TestSQLiteDriver_Retryable(t *testing.T) {
+	ctx := context.Background()
+	driver := &SQLiteDriver{}
+	path := filepath.Join(t.TempDir(), "busy.db")
+
+	holder, err := New(driver, WithDatabase(path), WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer holder.Close()
+	other, err := New(driver, WithDatabase(path), WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer other.Close()
+
+	if _, err := holder.Exec("CREATE TABLE items (id INTEGER)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	conn, err := holder.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get a connection: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("failed to take the write lock: %v", err)
+	}
+	defer conn.ExecContext(ctx, "ROLLBACK")
+
+	_, err = other.Exec("INSERT INTO items (id) VALUES (1)")
+	if err == nil || !driver.Retryable(err) {
+		t.Errorf("expected SQLITE_BUSY to be retryable, got %v", err)
+	}
+	if driver.Retryable(errors.New("other")) {
+		t.Error("expected other errors not to be retryable")
+	}
+}
@@ -1,11 +1,15 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
+	"time"
 
-	_ "github.com/microsoft/go-mssqldb"
+	mssql "github.com/microsoft/go-mssqldb"
 )
 
 type SQLServerDriver struct {
@@ -20,11 +24,36 @@ func (driver *SQLServerDriver) DSN(db *DB) string {
 	query.Set("database", db.database)
 	query.Set("timezone", db.timezone)
 
+	switch db.tls() {
+	case TLSDisable:
+		query.Set("encrypt", "disable")
+	case TLSPrefer:
+		query.Set("encrypt", "false")
+	case TLSRequire:
+		query.Set("encrypt", "true")
+		query.Set("trustservercertificate", "true")
+	case TLSVerifyCA, TLSVerifyFull:
+		query.Set("encrypt", "true")
+		query.Set("trustservercertificate", "false")
+	}
+	if db.tlsRootCA != "" {
+		query.Set("certificate", db.tlsRootCA)
+	}
+	if db.tlsServerName != "" {
+		query.Set("hostnameincertificate", db.tlsServerName)
+	}
+	if db.applicationName != "" {
+		query.Set("app name", db.applicationName)
+	}
+	if db.connectTimeout > 0 {
+		query.Set("dial timeout", db.timeoutSeconds())
+	}
+
 	u := &url.URL{
 		Scheme:   "sqlserver",
 		User:     url.UserPassword(db.username, db.password),
 		Host:     fmt.Sprintf("%s:%d", db.host, db.port),
-		RawQuery: query.Encode(),
+		RawQuery: db.query(query),
 	}
 
 	if db.instance != "" {
@@ -34,6 +63,53 @@ func (driver *SQLServerDriver) DSN(db *DB) string {
 	return u.String()
 }
 
+func (driver *SQLServerDriver) prepare(db *DB) error {
+	if db.tlsCert != "" {
+		return fmt.Errorf("sql: SQL Server TLS client certificate: %w", errors.ErrUnsupported)
+	}
+
+	return nil
+}
+
 func (driver *SQLServerDriver) Name() string {
 	return "sqlserver"
 }
+
+func (driver *SQLServerDriver) placeholder(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+func (driver *SQLServerDriver) migrationsTable(table string) string {
+	return fmt.Sprintf(`IF OBJECT_ID(N'%[1]s', N'U') IS NULL CREATE TABLE %[1]s (
+	version BIGINT PRIMARY KEY,
+	name NVARCHAR(255) NOT NULL,
+	checksum NVARCHAR(64) NOT NULL,
+	applied_at BIGINT NOT NULL
+)`, table)
+}
+
+// lock takes a session application lock with sp_getapplock.
+func (driver *SQLServerDriver) lock(ctx context.Context, conn *sql.Conn, name string, _ time.Duration) (func(context.Context) error, error) {
+	var result int
+	query := `DECLARE @result int;
+EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = -1;
+SELECT @result`
+	if err := conn.QueryRowContext(ctx, query, name).Scan(&result); err != nil {
+		return nil, err
+	}
+	if result < 0 {
+		return nil, fmt.Errorf("sp_getapplock returned %d", result)
+	}
+
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", name)
+		return err
+	}, nil
+}
+
+// Retryable reports whether err chose the transaction as a deadlock victim
+// (1205).
+func (driver *SQLServerDriver) Retryable(err error) bool {
+	var msErr mssql.Error
+	return errors.As(err, &msErr) && msErr.Number == 1205
+}
@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryClassifier is implemented by drivers that can tell which errors abort
+// a transaction that may succeed if run again, such as serialization
+// failures and deadlocks.
+type RetryClassifier interface {
+	Retryable(err error) bool
+}
+
+// TxOptions defines how WithTx runs a transaction.
+type TxOptions struct {
+	sql.TxOptions // isolation level and read-only mode
+
+	// Driver classifies errors as retryable when it implements
+	// RetryClassifier. Without one the transaction is not retried.
+	Driver Driver
+
+	MaxAttempts int           // runs of the function, including the first (default: 3)
+	BackoffBase time.Duration // delay before the first retry, doubled for every one (default: 10ms)
+	BackoffMax  time.Duration // maximum delay between retries (default: 1s)
+}
+
+// WithTx runs fn in a transaction of db, committing it if fn returns nil and
+// rolling it back if fn returns an error or panics. When fn or the commit
+// fails with an error the driver classifies as retryable, the whole
+// transaction runs again after a backoff with jitter, so fn must not have
+// side effects outside of tx. opts may be nil.
+func WithTx(ctx context.Context, db *sql.DB, opts *TxOptions, fn func(tx *sql.Tx) error) error {
+	cfg := TxOptions{
+		MaxAttempts: 3,
+		BackoffBase: 10 * time.Millisecond,
+		BackoffMax:  time.Second,
+	}
+	if opts != nil {
+		cfg.TxOptions = opts.TxOptions
+		cfg.Driver = opts.Driver
+		if opts.MaxAttempts > 0 {
+			cfg.MaxAttempts = opts.MaxAttempts
+		}
+		if opts.BackoffBase > 0 {
+			cfg.BackoffBase = opts.BackoffBase
+		}
+		if opts.BackoffMax > 0 {
+			cfg.BackoffMax = opts.BackoffMax
+		}
+	}
+	classifier, _ := cfg.Driver.(RetryClassifier)
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, &cfg.TxOptions, fn)
+		if err == nil || classifier == nil || attempt >= cfg.MaxAttempts || !classifier.Retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(txBackoff(cfg.BackoffBase, cfg.BackoffMax, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, fmt.Errorf("sql: rollback: %w", rbErr))
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// txBackoff returns the delay before the retry following attempt: half of
+// base doubled for every attempt, capped at maxDelay, plus up to as much jitter.
+func txBackoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	d := base << min(attempt-1, 30)
+	if d <= 0 || d > maxDelay {
+		d = maxDelay
+	}
+
+	half := d / 2
+	return half + rand.N(half+1)
+}
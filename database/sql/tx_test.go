@@ -0,0 +1,139 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// retryDriver classifies errRetry as retryable.
+type retryDriver struct {
+	MockDriver
+}
+
+var errRetry = errors.New("retry me")
+
+func (d *retryDriver) Retryable(err error) bool {
+	return errors.Is(err, errRetry)
+}
+
+func newTxDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := New(&SQLiteDriver{}, WithDatabase(filepath.Join(t.TempDir(), "tx.db")), WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	return db
+}
+
+func countItems(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+		t.Fatalf("failed to count items: %v", err)
+	}
+	return n
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	db := newTxDB(t)
+
+	err := WithTx(ctx, db, nil, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO items (id) VALUES (1)")
+		return err
+	})
+	if err != nil || countItems(t, db) != 1 {
+		t.Fatalf("expected the insert to be committed, got %v", err)
+	}
+
+	errBoom := errors.New("boom")
+	err = WithTx(ctx, db, nil, func(tx *sql.Tx) error {
+		_, _ = tx.Exec("INSERT INTO items (id) VALUES (2)")
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) || countItems(t, db) != 1 {
+		t.Errorf("expected the insert to be rolled back, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to be propagated")
+			}
+		}()
+		_ = WithTx(ctx, db, nil, func(tx *sql.Tx) error {
+			_, _ = tx.Exec("INSERT INTO items (id) VALUES (3)")
+			panic("boom")
+		})
+	}()
+	if countItems(t, db) != 1 {
+		t.Error("expected the insert to be rolled back after a panic")
+	}
+}
+
+func TestWithTx_Retry(t *testing.T) {
+	ctx := context.Background()
+	db := newTxDB(t)
+	opts := &TxOptions{Driver: &retryDriver{}, MaxAttempts: 3, BackoffBase: time.Millisecond}
+
+	calls := 0
+	err := WithTx(ctx, db, opts, func(tx *sql.Tx) error {
+		calls++
+		if _, err := tx.Exec("INSERT INTO items (id) VALUES (1)"); err != nil {
+			return err
+		}
+		if calls < 3 {
+			return errRetry
+		}
+		return nil
+	})
+	if err != nil || calls != 3 || countItems(t, db) != 1 {
+		t.Errorf("expected success on the third attempt, got %d calls (%v)", calls, err)
+	}
+
+	calls = 0
+	err = WithTx(ctx, db, opts, func(tx *sql.Tx) error {
+		calls++
+		return errRetry
+	})
+	if !errors.Is(err, errRetry) || calls != 3 {
+		t.Errorf("expected MaxAttempts calls, got %d (%v)", calls, err)
+	}
+
+	calls = 0
+	_ = WithTx(ctx, db, opts, func(tx *sql.Tx) error {
+		calls++
+		return errors.New("not retryable")
+	})
+	if calls != 1 {
+		t.Errorf("expected no retry of other errors, got %d calls", calls)
+	}
+
+	// Without a classifier nothing is retried.
+	calls = 0
+	_ = WithTx(ctx, db, &TxOptions{Driver: &MockDriver{}}, func(tx *sql.Tx) error {
+		calls++
+		return errRetry
+	})
+	if calls != 1 {
+		t.Errorf("expected no retry without a classifier, got %d calls", calls)
+	}
+}
+
+func TestTxBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 40; attempt++ {
+		d := txBackoff(10*time.Millisecond, time.Second, attempt)
+		if d < 0 || d > time.Second {
+			t.Errorf("attempt %d: backoff %s out of bounds", attempt, d)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stonear/go-dev-toolkit/cache"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheConfig defines the configuration of the response caching middleware.
+type CacheConfig struct {
+	TTL         time.Duration // freshness of responses without max-age or s-maxage, zero does not cache them
+	Vary        []string      // request headers that select different responses for the same URL
+	KeyPrefix   string        // prefix of the cache keys
+	MaxBodySize int           // larger responses are not cached
+	SingleHost  bool          // leave the scheme and host out of the cache keys
+}
+
+// CacheOption defines a functional option for the response caching middleware.
+type CacheOption func(*CacheConfig)
+
+// cachedResponse is a response stored by CacheResponses.
+type cachedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt int64       `json:"stored_at"` // unix milliseconds
+}
+
+// CacheResponses returns a middleware that stores GET and HEAD responses in c
+// and serves them until they expire, for handlers passed to New:
+//
+//   - Responses are keyed by method, scheme, host, path, query and the
+//     request headers listed with WithVary. A response whose own Vary header names any other
+//     request header is not cached.
+//   - Cache-Control is honored: requests with no-store bypass the cache and
+//     requests with no-cache or max-age=0 refresh it; responses with no-store,
+//     no-cache or private, or that set cookies, are not stored, and s-maxage
+//     or max-age set their TTL. Requests with an Authorization header are
+//     never cached.
+//   - Responses get an ETag (a hash of the body unless the handler set one),
+//     and requests whose If-None-Match matches it get a 304.
+//   - Concurrent misses for the same key run the handler once.
+//
+// Responses are buffered in full before they are written, so the middleware
+// is meant for read endpoints rather than streams. Cache errors are ignored
+// and the request is served by the handler.
+func CacheResponses(c cache.Cache, opts ...CacheOption) func(http.Handler) http.Handler {
+	cfg := &CacheConfig{
+		TTL:         time.Minute,
+		KeyPrefix:   "http:",
+		MaxBodySize: 1 << 20, // 1MB
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	for i, name := range cfg.Vary {
+		cfg.Vary[i] = http.CanonicalHeaderKey(name)
+	}
+
+	var flights singleflight.Group
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" || reqCC.has("no-store") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := cfg.key(r)
+			if !reqCC.has("no-cache") && reqCC["max-age"] != "0" {
+				if resp, err := cache.Get[cachedResponse](r.Context(), c, key); err == nil {
+					resp.write(w, r, "HIT")
+					return
+				}
+			}
+
+			var ran bool
+			v, _, _ := flights.Do(key, func() (any, error) {
+				ran = true
+				return cfg.record(c, key, next, r), nil
+			})
+
+			resp := v.(*recordedResponse)
+			if !ran && !resp.shared {
+				// The response was only meant for the request that made it.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			resp.write(w, r, "MISS")
+		})
+	}
+}
+
+// recordedResponse is the response of a miss, shared with the concurrent
+// requests for the same key when it could be cached.
+type recordedResponse struct {
+	cachedResponse
+	shared bool
+}
+
+// record runs next with r and stores its response when it may be cached.
+func (cfg *CacheConfig) record(c cache.Cache, key string, next http.Handler, r *http.Request) *recordedResponse {
+	rec := &responseRecorder{header: make(http.Header)}
+	next.ServeHTTP(rec, r)
+
+	resp := &recordedResponse{cachedResponse: cachedResponse{
+		Status:   rec.status(),
+		Header:   rec.header,
+		Body:     rec.body.Bytes(),
+		StoredAt: time.Now().UnixMilli(),
+	}}
+	if resp.Header.Get("ETag") == "" && resp.Status == http.StatusOK {
+		sum := sha256.Sum256(resp.Body)
+		resp.Header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+
+	ttl, ok := cfg.ttl(resp.Status, resp.Header, len(resp.Body))
+	if !ok {
+		return resp
+	}
+
+	resp.shared = true
+	_ = cache.Set(context.WithoutCancel(r.Context()), c, key, resp.cachedResponse, ttl)
+
+	return resp
+}
+
+// ttl returns how long a response may be stored, or false if it may not.
+func (cfg *CacheConfig) ttl(status int, header http.Header, size int) (time.Duration, bool) {
+	if !cacheableStatus(status) || size > cfg.MaxBodySize || header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+
+	for _, v := range header.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" || !slices.Contains(cfg.Vary, name) {
+				return 0, false
+			}
+		}
+	}
+
+	cc := parseCacheControl(header.Get("Cache-Control"))
+	if cc.has("no-store") || cc.has("no-cache") || cc.has("private") {
+		return 0, false
+	}
+
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[directive]; ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	return cfg.TTL, cfg.TTL > 0
+}
+
+// key hashes the request line and the Vary headers, so keys are safe for
+// every backend whatever the URL holds. The scheme and host are part of it,
+// so virtual hosts behind one middleware never share responses.
+func (cfg *CacheConfig) key(r *http.Request) string {
+	h := sha256.New()
+	if !cfg.SingleHost {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		_, _ = fmt.Fprintf(h, "%s://%s", scheme, strings.ToLower(r.Host))
+	}
+	_, _ = fmt.Fprintf(h, "%s?%s", r.URL.Path, r.URL.Query().Encode())
+	for _, name := range cfg.Vary {
+		_, _ = fmt.Fprintf(h, "\n%s: %s", name, strings.Join(r.Header.Values(name), ", "))
+	}
+
+	return cfg.KeyPrefix + r.Method + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// write sends the response, or a 304 when the request already has it.
+func (resp *cachedResponse) write(w http.ResponseWriter, r *http.Request, status string) {
+	header := w.Header()
+	for name, values := range resp.Header {
+		header[name] = slices.Clone(values)
+	}
+	header.Set("X-Cache", status)
+	if status == "HIT" {
+		age := max(0, time.Now().UnixMilli()-resp.StoredAt) / 1000
+		header.Set("Age", strconv.FormatInt(age, 10))
+	}
+
+	if etag := resp.Header.Get("ETag"); resp.Status == http.StatusOK && etag != "" && etagMatch(r.Header.Get("If-None-Match"), etag) {
+		for _, name := range []string{"Content-Length", "Content-Type"} {
+			header.Del(name)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
+
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusGone:
+		return true
+	default:
+		return false
+	}
+}
+
+// etagMatch reports whether an If-None-Match header matches etag, using the
+// weak comparison RFC 9110 requires for it.
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header string) cacheControl {
+	cc := make(cacheControl)
+	for directive := range strings.SplitSeq(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name == "" {
+			continue
+		}
+		cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// responseRecorder buffers a response so it can be stored and replayed.
+type responseRecorder struct {
+	header http.Header
+	body   bytes.Buffer
+	code   int
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+func (rec *responseRecorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+
+	return rec.code
+}
+
+// WithCacheTTL sets how long responses without max-age or s-maxage are cached
+// (default: 1 minute). Zero only caches responses that set one of them.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(cfg *CacheConfig) {
+		cfg.TTL = ttl
+	}
+}
+
+// WithVary adds request headers, such as Accept-Encoding or Accept-Language,
+// that select different responses for the same URL.
+func WithVary(headers ...string) CacheOption {
+	return func(cfg *CacheConfig) {
+		cfg.Vary = append(cfg.Vary, headers...)
+	}
+}
+
+// WithCacheKeyPrefix sets the prefix of the cache keys (default: "http:").
+func WithCacheKeyPrefix(prefix string) CacheOption {
+	return func(cfg *CacheConfig) {
+		cfg.KeyPrefix = prefix
+	}
+}
+
+// WithSingleHost leaves the scheme and host out of the cache keys, so a
+// response is shared by every Host the server answers to. Only use it when
+// they all serve the same content.
+func WithSingleHost() CacheOption {
+	return func(cfg *CacheConfig) {
+		cfg.SingleHost = true
+	}
+}
+
+// WithMaxBodySize sets the size above which responses are not cached
+// (default: 1MB).
+func WithMaxBodySize(size int) CacheOption {
+	return func(cfg *CacheConfig) {
+		cfg.MaxBodySize = size
+	}
+}
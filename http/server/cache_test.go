@@ -0,0 +1,236 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stonear/go-dev-toolkit/cache"
+)
+
+func serve(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCacheResponses(t *testing.T) {
+	c := cache.NewMemory(cache.WithCleanupInterval(0))
+	defer c.Close()
+
+	var calls atomic.Int32
+	h := CacheResponses(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("hello " + r.URL.Query().Get("name")))
+	}))
+
+	first := serve(h, http.MethodGet, "/greet?name=a", nil)
+	if first.Code != http.StatusOK || first.Body.String() != "hello a" || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("unexpected first response: %d %q %s", first.Code, first.Body.String(), first.Header().Get("X-Cache"))
+	}
+
+	second := serve(h, http.MethodGet, "/greet?name=a", nil)
+	if second.Body.String() != "hello a" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected a cached response, got %q %s", second.Body.String(), second.Header().Get("X-Cache"))
+	}
+	if second.Header().Get("Content-Type") != "text/plain" || second.Header().Get("Age") == "" {
+		t.Errorf("expected stored headers and Age, got %v", second.Header())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 handler call, got %d", n)
+	}
+
+	// The query is part of the key.
+	if rec := serve(h, http.MethodGet, "/greet?name=b", nil); rec.Body.String() != "hello b" {
+		t.Errorf("expected hello b, got %q", rec.Body.String())
+	}
+
+	// Other methods are never cached.
+	serve(h, http.MethodPost, "/greet?name=a", nil)
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected 3 handler calls, got %d", n)
+	}
+}
+
+func TestCacheResponses_ETag(t *testing.T) {
+	c := cache.NewMemory(cache.WithCleanupInterval(0))
+	defer c.Close()
+
+	h := CacheResponses(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("body"))
+	}))
+
+	etag := serve(h, http.MethodGet, "/", nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	rec := serve(h, http.MethodGet, "/", http.Header{"If-None-Match": {`"other", W/` + etag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("expected an empty 304, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("ETag") != etag {
+		t.Errorf("expected ETag %s on the 304, got %s", etag, rec.Header().Get("ETag"))
+	}
+
+	if rec := serve(h, http.MethodGet, "/", http.Header{"If-None-Match": {`"other"`}}); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for another ETag, got %d", rec.Code)
+	}
+}
+
+func TestCacheResponses_CacheControl(t *testing.T) {
+	tests := []struct {
+		name      string
+		reqHeader http.Header
+		respCC    string
+		setCookie bool
+		vary      string
+		wantCalls int32
+	}{
+		{"Default", nil, "", false, "", 1},
+		{"MaxAge", nil, "public, max-age=60", false, "", 1},
+		{"ResponseNoStore", nil, "no-store", false, "", 2},
+		{"ResponsePrivate", nil, "private, max-age=60", false, "", 2},
+		{"ResponseZeroMaxAge", nil, "max-age=0", false, "", 2},
+		{"SetCookie", nil, "", true, "", 2},
+		{"RequestNoStore", http.Header{"Cache-Control": {"no-store"}}, "", false, "", 2},
+		{"RequestNoCache", http.Header{"Cache-Control": {"no-cache"}}, "", false, "", 2},
+		{"Authorization", http.Header{"Authorization": {"Bearer token"}}, "", false, "", 2},
+		{"UnknownVary", nil, "", false, "Cookie", 2},
+		{"KnownVary", nil, "", false, "accept-language", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cache.NewMemory(cache.WithCleanupInterval(0))
+			defer c.Close()
+
+			var calls atomic.Int32
+			h := CacheResponses(c, WithVary("Accept-Language"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				if tt.respCC != "" {
+					w.Header().Set("Cache-Control", tt.respCC)
+				}
+				if tt.setCookie {
+					w.Header().Set("Set-Cookie", "session=1")
+				}
+				if tt.vary != "" {
+					w.Header().Set("Vary", tt.vary)
+				}
+				_, _ = w.Write([]byte("body"))
+			}))
+
+			for range 2 {
+				if rec := serve(h, http.MethodGet, "/", tt.reqHeader); rec.Body.String() != "body" {
+					t.Fatalf("expected body, got %q", rec.Body.String())
+				}
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("expected %d handler calls, got %d", tt.wantCalls, n)
+			}
+		})
+	}
+}
+
+func TestCacheResponses_Vary(t *testing.T) {
+	c := cache.NewMemory(cache.WithCleanupInterval(0))
+	defer c.Close()
+
+	h := CacheResponses(c, WithVary("Accept-Language"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+
+	serve(h, http.MethodGet, "/", http.Header{"Accept-Language": {"en"}})
+	if rec := serve(h, http.MethodGet, "/", http.Header{"Accept-Language": {"id"}}); rec.Body.String() != "id" {
+		t.Errorf("expected a response per Accept-Language, got %q", rec.Body.String())
+	}
+	if rec := serve(h, http.MethodGet, "/", http.Header{"Accept-Language": {"en"}}); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected a hit for the same Accept-Language, got %s", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestCacheResponses_Coalescing(t *testing.T) {
+	tests := []struct {
+		name      string
+		respCC    string
+		wantCalls int32
+	}{
+		{"Cacheable", "", 1},
+		// Private responses are not shared with concurrent requests.
+		{"Private", "private", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cache.NewMemory(cache.WithCleanupInterval(0))
+			defer c.Close()
+
+			var calls atomic.Int32
+			release := make(chan struct{})
+			h := CacheResponses(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					<-release
+				}
+				if tt.respCC != "" {
+					w.Header().Set("Cache-Control", tt.respCC)
+				}
+				_, _ = w.Write([]byte("body"))
+			}))
+
+			var wg sync.WaitGroup
+			for range 5 {
+				wg.Go(func() {
+					if rec := serve(h, http.MethodGet, "/slow", nil); rec.Body.String() != "body" {
+						t.Errorf("expected body, got %q", rec.Body.String())
+					}
+				})
+			}
+
+			// Let every request miss before the first one finishes.
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("expected %d handler calls, got %d", tt.wantCalls, n)
+			}
+		})
+	}
+}
+
+func TestCacheResponses_Host(t *testing.T) {
+	c := cache.NewMemory(cache.WithCleanupInterval(0))
+	defer c.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	})
+	get := func(h http.Handler, host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	h := CacheResponses(c)(handler)
+	get(h, "a.example.com")
+	if rec := get(h, "b.example.com"); rec.Body.String() != "b.example.com" {
+		t.Errorf("expected a response per host, got %q", rec.Body.String())
+	}
+
+	single := CacheResponses(c, WithSingleHost(), WithCacheKeyPrefix("single:"))(handler)
+	get(single, "a.example.com")
+	if rec := get(single, "b.example.com"); rec.Body.String() != "a.example.com" || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected hosts to share responses with WithSingleHost, got %q %s", rec.Body.String(), rec.Header().Get("X-Cache"))
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency is ready to serve traffic. The Ping
+// method of the cache backends and (*sql.DB).PingContext are both Checks.
+type Check func(ctx context.Context) error
+
+// ReadinessConfig defines the configuration of the readiness handler.
+type ReadinessConfig struct {
+	Errors bool // include the error of each failing check in the response
+}
+
+// ReadinessOption defines a functional option for the readiness handler.
+type ReadinessOption func(*ReadinessConfig)
+
+// Readiness returns a handler that runs every check concurrently and responds
+// 200 when all of them pass, or 503 with the names of the failing checks
+// otherwise. Each check is bounded by timeout, or by the request context when
+// timeout is zero.
+func Readiness(checks map[string]Check, timeout time.Duration, opts ...ReadinessOption) http.Handler {
+	cfg := &ReadinessConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		var (
+			mu       sync.Mutex
+			wg       sync.WaitGroup
+			failures = make(map[string]string)
+		)
+		for name, check := range checks {
+			wg.Go(func() {
+				if err := check(ctx); err != nil {
+					mu.Lock()
+					failures[name] = err.Error()
+					mu.Unlock()
+				}
+			})
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		body := map[string]any{"status": "ok"}
+		if len(failures) > 0 {
+			status = http.StatusServiceUnavailable
+			body = map[string]any{"status": "unavailable", "checks": slices.Sorted(maps.Keys(failures))}
+			if cfg.Errors {
+				body["errors"] = failures
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
+
+// WithCheckErrors includes the error of each failing check in the responses,
+// which may reveal hosts and addresses. Only use it when the endpoint is not
+// reachable from outside.
+func WithCheckErrors() ReadinessOption {
+	return func(cfg *ReadinessConfig) {
+		cfg.Errors = true
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantStatus int
+		wantFailed []string
+	}{
+		{"Ready", map[string]Check{"cache": ok, "db": ok}, http.StatusOK, nil},
+		{"Down", map[string]Check{"cache": down, "db": down, "queue": ok}, http.StatusServiceUnavailable, []string{"cache", "db"}},
+		{"Timeout", map[string]Check{"cache": slow}, http.StatusServiceUnavailable, []string{"cache"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Readiness(tt.checks, 10*time.Millisecond).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			var body struct {
+				Status string            `json:"status"`
+				Checks []string          `json:"checks"`
+				Errors map[string]string `json:"errors"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if !slices.Equal(body.Checks, tt.wantFailed) {
+				t.Errorf("expected failed checks %v, got %v", tt.wantFailed, body.Checks)
+			}
+			if body.Errors != nil {
+				t.Errorf("expected no errors in the response, got %v", body.Errors)
+			}
+		})
+	}
+}
+
+func TestReadiness_Errors(t *testing.T) {
+	checks := map[string]Check{
+		"cache": func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.5:6379: connection refused") },
+		"db":    func(ctx context.Context) error { return nil },
+	}
+
+	rec := httptest.NewRecorder()
+	Readiness(checks, 0).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("expected the error to stay out of the response, got %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	Readiness(checks, 0, WithCheckErrors()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body struct {
+		Errors map[string]string `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if len(body.Errors) != 1 || !strings.Contains(body.Errors["cache"], "connection refused") {
+		t.Errorf("expected the error of cache, got %v", body.Errors)
+	}
+}
@@ -0,0 +1,324 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/stonear/go-dev-toolkit/messaging"
+
+// payloadField is the stream entry field holding the payload; every other
+// field is a header.
+const payloadField = "payload"
+
+// Config defines the configuration of a Broker.
+type Config struct {
+	MaxLen    int64         // Streams: approximate number of entries Add keeps per stream, zero keeps every entry
+	BatchSize int64         // Streams: entries read by each XREADGROUP
+	Block     time.Duration // Streams: how long XREADGROUP waits for new entries
+	ClaimIdle time.Duration // Streams: idle time after which pending entries of other consumers are reclaimed, zero disables reclaiming
+
+	ErrorHandler func(msg *Message, err error) // called when a handler fails
+}
+
+// Option defines a functional option for configuring a Broker.
+type Option func(*Config)
+
+// Message is a message received from a channel or a stream.
+type Message struct {
+	ID      string            // stream entry ID, empty for pub/sub messages
+	Channel string            // channel or stream the message was received from
+	Payload []byte            // message body
+	Headers map[string]string // metadata, including the trace context of the producer
+}
+
+// Handler processes a message. For streams, the entry is acknowledged when the
+// handler returns nil and stays pending otherwise, to be reclaimed later.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Broker publishes and consumes messages over the client of a cache backend,
+// with the trace context of the producer carried in message headers.
+type Broker struct {
+	backend backend
+	system  string
+	cfg     *Config
+	tracer  trace.Tracer
+}
+
+// backend is the set of commands a Broker needs from Redis or Valkey.
+type backend interface {
+	publish(ctx context.Context, channel, message string) error
+	subscribe(ctx context.Context, channels []string, fn func(channel, message string)) error
+	add(ctx context.Context, stream string, fields map[string]string, maxLen int64) (string, error)
+	createGroup(ctx context.Context, stream, group string) error
+	readGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]entry, error)
+	ack(ctx context.Context, stream, group, id string) error
+	autoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]entry, string, error)
+}
+
+// entry is a stream entry.
+type entry struct {
+	id     string
+	fields map[string]string
+}
+
+func newBroker(b backend, system string, opts []Option) *Broker {
+	cfg := &Config{
+		BatchSize: 10,
+		Block:     5 * time.Second,
+		ClaimIdle: time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Broker{
+		backend: b,
+		system:  system,
+		cfg:     cfg,
+		tracer:  otel.Tracer(instrumentationName),
+	}
+}
+
+// Publish sends payload to the subscribers of channel. Messages published
+// while nobody is subscribed are lost; use streams for durable delivery.
+func (b *Broker) Publish(ctx context.Context, channel string, payload []byte) error {
+	ctx, span := b.startProducer(ctx, "publish", channel)
+	defer span.End()
+
+	env := envelope{Headers: b.inject(ctx), Payload: payload}
+	data, err := json.Marshal(env)
+	if err == nil {
+		err = b.backend.publish(ctx, channel, string(data))
+	}
+
+	return endSpan(span, err)
+}
+
+// Subscribe calls handler with every message published to channels until ctx
+// is done. Messages are handled one at a time.
+func (b *Broker) Subscribe(ctx context.Context, handler Handler, channels ...string) error {
+	return b.backend.subscribe(ctx, channels, func(channel, message string) {
+		var env envelope
+		if err := json.Unmarshal([]byte(message), &env); err != nil || env.Payload == nil {
+			// Published by a client that does not use envelopes.
+			env = envelope{Payload: []byte(message)}
+		}
+
+		b.process(ctx, handler, &Message{Channel: channel, Payload: env.Payload, Headers: env.Headers})
+	})
+}
+
+// Add appends payload to stream and returns the ID of the new entry.
+func (b *Broker) Add(ctx context.Context, stream string, payload []byte) (string, error) {
+	ctx, span := b.startProducer(ctx, "send", stream)
+	defer span.End()
+
+	fields := b.inject(ctx)
+	fields[payloadField] = string(payload)
+
+	id, err := b.backend.add(ctx, stream, fields, b.cfg.MaxLen)
+	if err == nil {
+		span.SetAttributes(semconv.MessagingMessageID(id))
+	}
+
+	return id, endSpan(span, err)
+}
+
+// Consume reads stream as consumer of group, creating the group if needed,
+// and calls handler with every entry until ctx is done. Entries that stay
+// pending for ClaimIdle, because their consumer failed or crashed, are
+// reclaimed and handled again. After ctx is done, Consume returns once the
+// current XREADGROUP returns, within Block.
+func (b *Broker) Consume(ctx context.Context, stream, group, consumer string, handler Handler) error {
+	if err := b.backend.createGroup(ctx, stream, group); err != nil {
+		return err
+	}
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if b.cfg.ClaimIdle > 0 && time.Since(lastClaim) >= b.cfg.ClaimIdle {
+			if err := b.reclaim(ctx, stream, group, consumer, handler); err != nil {
+				return ignoreDone(ctx, err)
+			}
+			lastClaim = time.Now()
+		}
+
+		entries, err := b.backend.readGroup(ctx, stream, group, consumer, b.cfg.BatchSize, b.cfg.Block)
+		if err != nil {
+			return ignoreDone(ctx, err)
+		}
+
+		if err := b.handle(ctx, stream, group, handler, entries); err != nil {
+			return ignoreDone(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+// reclaim takes over the entries pending for longer than ClaimIdle and
+// handles them.
+func (b *Broker) reclaim(ctx context.Context, stream, group, consumer string, handler Handler) error {
+	start := "0-0"
+	for {
+		entries, next, err := b.backend.autoClaim(ctx, stream, group, consumer, b.cfg.ClaimIdle, start, b.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		if err := b.handle(ctx, stream, group, handler, entries); err != nil {
+			return err
+		}
+
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// handle processes entries and acknowledges those handled successfully.
+func (b *Broker) handle(ctx context.Context, stream, group string, handler Handler, entries []entry) error {
+	for _, e := range entries {
+		msg := &Message{ID: e.id, Channel: stream, Headers: make(map[string]string, len(e.fields))}
+		for field, value := range e.fields {
+			if field == payloadField {
+				msg.Payload = []byte(value)
+			} else {
+				msg.Headers[field] = value
+			}
+		}
+
+		if !b.process(ctx, handler, msg, semconv.MessagingConsumerGroupName(group), semconv.MessagingMessageID(e.id)) {
+			continue
+		}
+
+		if err := b.backend.ack(ctx, stream, group, e.id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// process runs handler in a consumer span linked to the producer span, and
+// reports whether it succeeded.
+func (b *Broker) process(ctx context.Context, handler Handler, msg *Message, attrs ...attribute.KeyValue) bool {
+	producer := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+
+	ctx, span := b.tracer.Start(ctx, "process "+msg.Channel,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.LinkFromContext(producer)),
+		trace.WithAttributes(b.attributes("process", msg.Channel)...),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	err := handler(ctx, msg)
+	if err != nil && b.cfg.ErrorHandler != nil {
+		b.cfg.ErrorHandler(msg, err)
+	}
+
+	return endSpan(span, err) == nil
+}
+
+func (b *Broker) startProducer(ctx context.Context, operation, destination string) (context.Context, trace.Span) {
+	return b.tracer.Start(ctx, operation+" "+destination,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(b.attributes(operation, destination)...),
+	)
+}
+
+func (b *Broker) attributes(operation, destination string) []attribute.KeyValue {
+	opType := semconv.MessagingOperationTypeSend
+	if operation == "process" {
+		opType = semconv.MessagingOperationTypeProcess
+	}
+
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String(b.system),
+		semconv.MessagingDestinationName(destination),
+		semconv.MessagingOperationName(operation),
+		opType,
+	}
+}
+
+// inject returns headers holding the trace context of ctx.
+func (b *Broker) inject(ctx context.Context) map[string]string {
+	headers := make(map[string]string)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+
+	return headers
+}
+
+// envelope carries the headers of a pub/sub message, as Redis and Valkey
+// messages have a payload only.
+type envelope struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload []byte            `json:"payload"`
+}
+
+func endSpan(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// ignoreDone drops the error of a call interrupted because ctx is done.
+func ignoreDone(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+// WithMaxLen trims streams written by Add to about maxLen entries.
+func WithMaxLen(maxLen int64) Option {
+	return func(cfg *Config) {
+		cfg.MaxLen = maxLen
+	}
+}
+
+// WithBatchSize sets how many entries each read returns at most (default: 10).
+func WithBatchSize(size int64) Option {
+	return func(cfg *Config) {
+		cfg.BatchSize = size
+	}
+}
+
+// WithBlock sets how long a read waits for new entries (default: 5s).
+func WithBlock(block time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Block = block
+	}
+}
+
+// WithClaimIdle sets how long an entry stays pending before another consumer
+// reclaims it (default: 1 minute). Zero disables reclaiming.
+func WithClaimIdle(idle time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.ClaimIdle = idle
+	}
+}
+
+// WithErrorHandler sets a function called when a handler returns an error.
+// Errors are dropped by default; failed stream entries are retried anyway.
+func WithErrorHandler(fn func(msg *Message, err error)) Option {
+	return func(cfg *Config) {
+		cfg.ErrorHandler = fn
+	}
+}
@@ -0,0 +1,281 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeBackend keeps channels and streams in memory.
+type fakeBackend struct {
+	mu          sync.Mutex
+	subscribers map[string][]func(channel, message string)
+	subscribed  chan struct{}
+
+	seq     int
+	streams map[string][]entry
+	read    map[string]int                // next entry index per stream and group
+	pending map[string]map[string]fakePEL // pending entries per stream and group
+}
+
+type fakePEL struct {
+	entry
+	deliveredAt time.Time
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		subscribers: make(map[string][]func(channel, message string)),
+		subscribed:  make(chan struct{}, 1),
+		streams:     make(map[string][]entry),
+		read:        make(map[string]int),
+		pending:     make(map[string]map[string]fakePEL),
+	}
+}
+
+func (f *fakeBackend) publish(ctx context.Context, channel, message string) error {
+	f.mu.Lock()
+	subs := f.subscribers[channel]
+	f.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(channel, message)
+	}
+	return nil
+}
+
+func (f *fakeBackend) subscribe(ctx context.Context, channels []string, fn func(channel, message string)) error {
+	f.mu.Lock()
+	for _, channel := range channels {
+		f.subscribers[channel] = append(f.subscribers[channel], fn)
+	}
+	f.mu.Unlock()
+
+	f.subscribed <- struct{}{}
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeBackend) add(ctx context.Context, stream string, fields map[string]string, maxLen int64) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	id := fmt.Sprintf("%d-0", f.seq)
+	f.streams[stream] = append(f.streams[stream], entry{id: id, fields: fields})
+	return id, nil
+}
+
+func (f *fakeBackend) createGroup(ctx context.Context, stream, group string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.pending[stream+"/"+group] == nil {
+		f.pending[stream+"/"+group] = make(map[string]fakePEL)
+	}
+	return nil
+}
+
+func (f *fakeBackend) readGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]entry, error) {
+	f.mu.Lock()
+	key := stream + "/" + group
+	next := f.read[key]
+	entries := f.streams[stream][next:min(next+int(count), len(f.streams[stream]))]
+	f.read[key] = next + len(entries)
+	for _, e := range entries {
+		f.pending[key][e.id] = fakePEL{entry: e, deliveredAt: time.Now()}
+	}
+	f.mu.Unlock()
+
+	if len(entries) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(block):
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeBackend) ack(ctx context.Context, stream, group, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.pending[stream+"/"+group], id)
+	return nil
+}
+
+func (f *fakeBackend) autoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]entry, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var entries []entry
+	for id, p := range f.pending[stream+"/"+group] {
+		if time.Since(p.deliveredAt) >= minIdle {
+			p.deliveredAt = time.Now()
+			f.pending[stream+"/"+group][id] = p
+			entries = append(entries, p.entry)
+		}
+	}
+	return entries, "0-0", nil
+}
+
+func (f *fakeBackend) pendingCount(stream, group string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.pending[stream+"/"+group])
+}
+
+// newTestBroker returns a broker over a fake backend whose spans are recorded.
+func newTestBroker(t *testing.T, opts ...Option) (*Broker, *fakeBackend, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	f := newFakeBackend()
+	b := newBroker(f, "redis", opts)
+	b.tracer = tp.Tracer(instrumentationName)
+
+	return b, f, recorder
+}
+
+// linkedToProducer checks that want consumer spans link to a producer span.
+func linkedToProducer(t *testing.T, recorder *tracetest.SpanRecorder, want int) {
+	t.Helper()
+
+	producers := make(map[trace.SpanID]bool)
+	var consumers []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.SpanKind() {
+		case trace.SpanKindProducer:
+			producers[span.SpanContext().SpanID()] = true
+		case trace.SpanKindConsumer:
+			consumers = append(consumers, span)
+		}
+	}
+
+	linked := 0
+	for _, span := range consumers {
+		for _, link := range span.Links() {
+			if producers[link.SpanContext.SpanID()] {
+				linked++
+			}
+		}
+	}
+	if linked != want {
+		t.Errorf("expected %d consumer spans linked to a producer, got %d", want, linked)
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	b, f, recorder := newTestBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan *Message, 2)
+	done := make(chan error)
+	go func() {
+		done <- b.Subscribe(ctx, func(ctx context.Context, msg *Message) error {
+			received <- msg
+			return nil
+		}, "orders")
+	}()
+	<-f.subscribed
+
+	if err := b.Publish(context.Background(), "orders", []byte("created")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	msg := <-received
+	if msg.Channel != "orders" || string(msg.Payload) != "created" || msg.Headers["traceparent"] == "" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	// Messages from other publishers are passed through as they are.
+	_ = f.publish(ctx, "orders", "raw")
+	if msg := <-received; string(msg.Payload) != "raw" {
+		t.Errorf("expected raw payload, got %q", msg.Payload)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected nil after cancel, got %v", err)
+	}
+	// The raw message has no trace context.
+	linkedToProducer(t, recorder, 1)
+}
+
+func TestConsume(t *testing.T) {
+	var mu sync.Mutex
+	var handlerErrs []error
+	b, f, recorder := newTestBroker(t,
+		WithBlock(time.Millisecond),
+		WithClaimIdle(20*time.Millisecond),
+		WithErrorHandler(func(msg *Message, err error) {
+			mu.Lock()
+			handlerErrs = append(handlerErrs, err)
+			mu.Unlock()
+		}),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, payload := range []string{"a", "b", "c"} {
+		if _, err := b.Add(context.Background(), "jobs", []byte(payload)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	var seen sync.Map
+	failed := false
+	all := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- b.Consume(ctx, "jobs", "workers", "worker-1", func(ctx context.Context, msg *Message) error {
+			if string(msg.Payload) == "b" && !failed {
+				failed = true
+				return errors.New("temporary failure")
+			}
+			seen.Store(string(msg.Payload), msg.ID)
+			if string(msg.Payload) == "b" {
+				close(all)
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case <-all:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the failed entry to be reclaimed and handled again")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected nil after cancel, got %v", err)
+	}
+
+	for _, payload := range []string{"a", "b", "c"} {
+		if _, ok := seen.Load(payload); !ok {
+			t.Errorf("expected %s to be handled", payload)
+		}
+	}
+	if n := f.pendingCount("jobs", "workers"); n != 0 {
+		t.Errorf("expected every entry to be acknowledged, got %d pending", n)
+	}
+	if len(handlerErrs) != 1 {
+		t.Errorf("expected 1 handler error, got %v", handlerErrs)
+	}
+	// b is processed twice.
+	linkedToProducer(t, recorder, 4)
+}
@@ -0,0 +1,122 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stonear/go-dev-toolkit/cache"
+)
+
+type redisBackend struct {
+	client redis.UniversalClient
+}
+
+// NewRedis returns a Broker that shares the client of c.
+func NewRedis(c *cache.RedisCache, opts ...Option) *Broker {
+	return newBroker(redisBackend{client: c.Client()}, "redis", opts)
+}
+
+func (r redisBackend) publish(ctx context.Context, channel, message string) error {
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+func (r redisBackend) subscribe(ctx context.Context, channels []string, fn func(channel, message string)) error {
+	sub := r.client.Subscribe(ctx, channels...)
+	defer sub.Close()
+
+	// Wait for the confirmation, so a failed subscription is reported.
+	if _, err := sub.Receive(ctx); err != nil {
+		return ignoreDone(ctx, err)
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			fn(msg.Channel, msg.Payload)
+		}
+	}
+}
+
+func (r redisBackend) add(ctx context.Context, stream string, fields map[string]string, maxLen int64) (string, error) {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: maxLen > 0,
+		Values: fields,
+	}).Result()
+}
+
+func (r redisBackend) createGroup(ctx context.Context, stream, group string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+
+	return err
+}
+
+func (r redisBackend) readGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]entry, error) {
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for _, s := range streams {
+		entries = append(entries, redisEntries(s.Messages)...)
+	}
+
+	return entries, nil
+}
+
+func (r redisBackend) ack(ctx context.Context, stream, group, id string) error {
+	return r.client.XAck(ctx, stream, group, id).Err()
+}
+
+func (r redisBackend) autoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]entry, string, error) {
+	msgs, next, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    start,
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return redisEntries(msgs), next, nil
+}
+
+func redisEntries(msgs []redis.XMessage) []entry {
+	entries := make([]entry, len(msgs))
+	for i, msg := range msgs {
+		fields := make(map[string]string, len(msg.Values))
+		for field, value := range msg.Values {
+			fields[field] = fmt.Sprint(value)
+		}
+		entries[i] = entry{id: msg.ID, fields: fields}
+	}
+
+	return entries
+}
@@ -0,0 +1,112 @@
+package messaging
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/stonear/go-dev-toolkit/cache"
+	"github.com/valkey-io/valkey-go"
+)
+
+type valkeyBackend struct {
+	client valkey.Client
+}
+
+// NewValkey returns a Broker that shares the client of c.
+func NewValkey(c *cache.ValkeyCache, opts ...Option) *Broker {
+	return newBroker(valkeyBackend{client: c.Client()}, "valkey", opts)
+}
+
+func (v valkeyBackend) publish(ctx context.Context, channel, message string) error {
+	return v.client.Do(ctx, v.client.B().Publish().Channel(channel).Message(message).Build()).Error()
+}
+
+func (v valkeyBackend) subscribe(ctx context.Context, channels []string, fn func(channel, message string)) error {
+	err := v.client.Receive(ctx, v.client.B().Subscribe().Channel(channels...).Build(), func(msg valkey.PubSubMessage) {
+		fn(msg.Channel, msg.Message)
+	})
+
+	return ignoreDone(ctx, err)
+}
+
+func (v valkeyBackend) add(ctx context.Context, stream string, fields map[string]string, maxLen int64) (string, error) {
+	key := v.client.B().Xadd().Key(stream)
+
+	var cmd valkey.Completed
+	if maxLen > 0 {
+		fv := key.Maxlen().Almost().Threshold(strconv.FormatInt(maxLen, 10)).Id("*").FieldValue()
+		for field, value := range fields {
+			fv = fv.FieldValue(field, value)
+		}
+		cmd = fv.Build()
+	} else {
+		fv := key.Id("*").FieldValue()
+		for field, value := range fields {
+			fv = fv.FieldValue(field, value)
+		}
+		cmd = fv.Build()
+	}
+
+	return v.client.Do(ctx, cmd).ToString()
+}
+
+func (v valkeyBackend) createGroup(ctx context.Context, stream, group string) error {
+	cmd := v.client.B().XgroupCreate().Key(stream).Group(group).Id("0").Mkstream().Build()
+	err := v.client.Do(ctx, cmd).Error()
+	if valkey.IsValkeyBusyGroup(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (v valkeyBackend) readGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]entry, error) {
+	cmd := v.client.B().Xreadgroup().Group(group, consumer).Count(count).Block(block.Milliseconds()).
+		Streams().Key(stream).Id(">").Build()
+	streams, err := v.client.Do(ctx, cmd).AsXRead()
+	if valkey.IsValkeyNil(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return valkeyEntries(streams[stream]), nil
+}
+
+func (v valkeyBackend) ack(ctx context.Context, stream, group, id string) error {
+	return v.client.Do(ctx, v.client.B().Xack().Key(stream).Group(group).Id(id).Build()).Error()
+}
+
+func (v valkeyBackend) autoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]entry, string, error) {
+	cmd := v.client.B().Xautoclaim().Key(stream).Group(group).Consumer(consumer).
+		MinIdleTime(strconv.FormatInt(minIdle.Milliseconds(), 10)).Start(start).Count(count).Build()
+	res, err := v.client.Do(ctx, cmd).ToArray()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(res) < 2 {
+		return nil, "", nil
+	}
+
+	next, err := res[0].ToString()
+	if err != nil {
+		return nil, "", err
+	}
+	msgs, err := res[1].AsXRange()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return valkeyEntries(msgs), next, nil
+}
+
+func valkeyEntries(msgs []valkey.XRangeEntry) []entry {
+	entries := make([]entry, len(msgs))
+	for i, msg := range msgs {
+		entries[i] = entry{id: msg.ID, fields: msg.FieldValues}
+	}
+
+	return entries
+}
@@ -0,0 +1,552 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/stonear/go-dev-toolkit/cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/stonear/go-dev-toolkit/queue"
+
+var (
+	// ErrPermanent marks handler errors that must not be retried: a job
+	// whose handler returns an error wrapping it is dead-lettered at once.
+	ErrPermanent = errors.New("permanent failure")
+
+	// ErrJobNotFound is returned by Requeue when the job is not dead-lettered.
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// defaultDeadLimit is the page size of Dead when its limit is not positive.
+const defaultDeadLimit = 100
+
+// Config defines the configuration of a Queue.
+type Config struct {
+	Concurrency    int           // jobs run at the same time by each Work call
+	MaxAttempts    int           // runs of a job before it is dead-lettered, unless set per job
+	BackoffBase    time.Duration // delay before the first retry, doubled for every retry after it
+	BackoffMax     time.Duration // upper bound of the retry delay
+	PollInterval   time.Duration // how long Work waits before looking again when no job is ready
+	Lease          time.Duration // how long a running job is hidden from other workers
+	HandlerTimeout time.Duration // how long a handler may run, kept shorter than Lease; zero means nine tenths of Lease
+	DrainTimeout   time.Duration // how long Work waits for running jobs after ctx is done, zero waits until they return
+	DeadRetention  time.Duration // how long dead-lettered jobs are kept, zero keeps them forever
+
+	Codec     cache.Codec // encoding of typed payloads
+	KeyPrefix string      // Redis and Valkey: prefix of the keys
+	Table     string      // SQL: name of the jobs table
+
+	ErrorHandler func(job *Job, err error) // called when a handler or the backend fails
+}
+
+// Option defines a functional option for configuring a Queue.
+type Option func(*Config)
+
+// Job is a unit of work stored in a queue.
+type Job struct {
+	ID          string
+	Queue       string
+	Payload     []byte
+	Headers     map[string]string // metadata, including the trace context of the producer
+	Attempt     int               // 1 on the first run
+	MaxAttempts int
+	LastError   string // error of the previous run
+	CreatedAt   time.Time
+
+	codec cache.Codec
+}
+
+// Decode decodes the payload of the job into v with the codec of the queue.
+func (j *Job) Decode(v any) error {
+	codec := j.codec
+	if codec == nil {
+		codec = cache.JSON
+	}
+
+	return codec.Unmarshal(j.Payload, v)
+}
+
+// Handler runs a job. The job is deleted when it returns nil, and retried
+// with exponential backoff otherwise until it runs out of attempts.
+type Handler func(ctx context.Context, job *Job) error
+
+// Queue enqueues jobs and runs them with worker pools.
+type Queue struct {
+	backend backend
+	system  string
+	cfg     *Config
+	tracer  trace.Tracer
+}
+
+// backend stores jobs. A claimed job is hidden until its lease ends, so the
+// jobs of a crashed worker are run again.
+type backend interface {
+	enqueue(ctx context.Context, job *Job, runAt time.Time) error
+	claim(ctx context.Context, queue string, n int, lease time.Duration) ([]*Job, error)
+	complete(ctx context.Context, job *Job) error
+	retry(ctx context.Context, job *Job, runAt time.Time) error
+	bury(ctx context.Context, job *Job, expired time.Time) error
+	dead(ctx context.Context, queue string, limit int) ([]*Job, error)
+	requeue(ctx context.Context, queue, id string) error
+}
+
+func newConfig(opts []Option) *Config {
+	cfg := &Config{
+		Concurrency:   10,
+		MaxAttempts:   5,
+		BackoffBase:   time.Second,
+		BackoffMax:    time.Hour,
+		PollInterval:  time.Second,
+		Lease:         5 * time.Minute,
+		DrainTimeout:  30 * time.Second,
+		DeadRetention: 7 * 24 * time.Hour,
+		Codec:         cache.JSON,
+		KeyPrefix:     "queue:",
+		Table:         "queue_jobs",
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+func newQueue(b backend, system string, cfg *Config) *Queue {
+	return &Queue{
+		backend: b,
+		system:  system,
+		cfg:     cfg,
+		tracer:  otel.Tracer(instrumentationName),
+	}
+}
+
+// jobConfig holds the options of a single Enqueue call.
+type jobConfig struct {
+	runAt       time.Time
+	maxAttempts int
+}
+
+// JobOption defines a functional option for a single Enqueue call.
+type JobOption func(*jobConfig)
+
+// Enqueue stores a job with payload in queue and returns its ID.
+func (q *Queue) Enqueue(ctx context.Context, queue string, payload []byte, opts ...JobOption) (string, error) {
+	jc := &jobConfig{maxAttempts: q.cfg.MaxAttempts}
+	for _, opt := range opts {
+		opt(jc)
+	}
+
+	ctx, span := q.tracer.Start(ctx, "send "+queue,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(q.attributes("send", queue)...),
+	)
+	defer span.End()
+
+	job := &Job{
+		ID:          newID(),
+		Queue:       queue,
+		Payload:     payload,
+		Headers:     make(map[string]string),
+		MaxAttempts: max(1, jc.maxAttempts),
+		CreatedAt:   time.Now(),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(job.Headers))
+	span.SetAttributes(semconv.MessagingMessageID(job.ID))
+
+	runAt := jc.runAt
+	if runAt.IsZero() {
+		runAt = job.CreatedAt
+	}
+
+	if err := q.backend.enqueue(ctx, job, runAt); err != nil {
+		return "", endSpan(span, err)
+	}
+
+	return job.ID, nil
+}
+
+// Enqueue encodes payload with the codec of q and stores it as a job in
+// queue. It returns the ID of the job.
+func Enqueue[T any](ctx context.Context, q *Queue, queue string, payload T, opts ...JobOption) (string, error) {
+	data, err := q.cfg.Codec.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return q.Enqueue(ctx, queue, data, opts...)
+}
+
+// Handle returns a Handler that decodes the payload of jobs into a T before
+// calling fn. Jobs whose payload cannot be decoded are dead-lettered.
+func Handle[T any](fn func(ctx context.Context, payload T) error) Handler {
+	return func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := job.Decode(&payload); err != nil {
+			return fmt.Errorf("%w: decode payload: %w", ErrPermanent, err)
+		}
+
+		return fn(ctx, payload)
+	}
+}
+
+// Work runs the jobs of queue with handler, up to Concurrency at a time,
+// until ctx is done. It then stops claiming jobs and waits for the running
+// ones to return, cancelling their context after DrainTimeout. Backend
+// errors are passed to the ErrorHandler and retried after PollInterval.
+func (q *Queue) Work(ctx context.Context, queue string, handler Handler) error {
+	slots := make(chan struct{}, max(1, q.cfg.Concurrency))
+	for range cap(slots) {
+		slots <- struct{}{}
+	}
+
+	// Running jobs outlive ctx, so they can finish during the drain.
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	for ctx.Err() == nil {
+		n := q.acquire(ctx, slots)
+		if n == 0 {
+			break
+		}
+
+		jobs, err := q.backend.claim(ctx, queue, n, q.cfg.Lease)
+		if err != nil && ctx.Err() == nil {
+			q.report(nil, err)
+		}
+
+		for _, job := range jobs {
+			job.codec = q.cfg.Codec
+			wg.Go(func() {
+				defer func() { slots <- struct{}{} }()
+				q.run(jobCtx, job, handler)
+			})
+		}
+
+		for range n - len(jobs) {
+			slots <- struct{}{}
+		}
+
+		if len(jobs) < n {
+			select {
+			case <-ctx.Done():
+			case <-time.After(q.cfg.PollInterval):
+			}
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	if q.cfg.DrainTimeout > 0 {
+		select {
+		case <-drained:
+			return nil
+		case <-time.After(q.cfg.DrainTimeout):
+			cancelJobs()
+		}
+	}
+	<-drained
+
+	return nil
+}
+
+// acquire waits for a free slot and takes every other free one, returning
+// how many it took, or zero once ctx is done.
+func (q *Queue) acquire(ctx context.Context, slots chan struct{}) int {
+	select {
+	case <-ctx.Done():
+		return 0
+	case <-slots:
+	}
+
+	n := 1
+	for {
+		select {
+		case <-slots:
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+// run handles job in a consumer span linked to the producer span and
+// records the outcome.
+func (q *Queue) run(ctx context.Context, job *Job, handler Handler) {
+	producer := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(job.Headers))
+
+	ctx, span := q.tracer.Start(ctx, "process "+job.Queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.LinkFromContext(producer)),
+		trace.WithAttributes(q.attributes("process", job.Queue)...),
+		trace.WithAttributes(
+			semconv.MessagingMessageID(job.ID),
+			attribute.Int("queue.job.attempt", job.Attempt),
+		),
+	)
+	defer span.End()
+
+	handlerCtx, cancel := context.WithTimeout(ctx, q.cfg.handlerTimeout())
+	err := call(handlerCtx, handler, job)
+	cancel()
+
+	// The outcome is recorded even when the drain cancelled ctx.
+	ctx = context.WithoutCancel(ctx)
+
+	var storeErr error
+	switch {
+	case err == nil:
+		storeErr = q.backend.complete(ctx, job)
+	case errors.Is(err, ErrPermanent) || job.Attempt >= job.MaxAttempts:
+		job.LastError = err.Error()
+		span.SetAttributes(attribute.Bool("queue.job.dead", true))
+		var expired time.Time
+		if q.cfg.DeadRetention > 0 {
+			expired = time.Now().Add(-q.cfg.DeadRetention)
+		}
+		storeErr = q.backend.bury(ctx, job, expired)
+	default:
+		job.LastError = err.Error()
+		storeErr = q.backend.retry(ctx, job, time.Now().Add(q.cfg.backoff(job.Attempt)))
+	}
+
+	if err != nil {
+		q.report(job, err)
+	}
+	if storeErr != nil {
+		q.report(job, storeErr)
+	}
+
+	_ = endSpan(span, errors.Join(err, storeErr))
+}
+
+// call runs handler, turning a panic into an error.
+func call(ctx context.Context, handler Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// Dead returns up to limit dead-lettered jobs of queue, oldest first, or up
+// to 100 if limit is not positive.
+func (q *Queue) Dead(ctx context.Context, queue string, limit int) ([]*Job, error) {
+	if limit <= 0 {
+		limit = defaultDeadLimit
+	}
+
+	jobs, err := q.backend.dead(ctx, queue, limit)
+	for _, job := range jobs {
+		job.codec = q.cfg.Codec
+	}
+
+	return jobs, err
+}
+
+// Requeue moves a dead-lettered job back to its queue with its attempts
+// reset. It returns ErrJobNotFound if no such job is dead-lettered.
+func (q *Queue) Requeue(ctx context.Context, queue, id string) error {
+	return q.backend.requeue(ctx, queue, id)
+}
+
+func (q *Queue) report(job *Job, err error) {
+	if q.cfg.ErrorHandler != nil {
+		q.cfg.ErrorHandler(job, err)
+	}
+}
+
+func (q *Queue) attributes(operation, queue string) []attribute.KeyValue {
+	opType := semconv.MessagingOperationTypeSend
+	if operation == "process" {
+		opType = semconv.MessagingOperationTypeProcess
+	}
+
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String(q.system),
+		semconv.MessagingDestinationName(queue),
+		semconv.MessagingOperationName(operation),
+		opType,
+	}
+}
+
+// handlerTimeout returns HandlerTimeout, or nine tenths of Lease if it is
+// unset or not shorter than Lease, so the outcome of a job is stored before
+// another worker can claim it.
+func (cfg *Config) handlerTimeout() time.Duration {
+	if cfg.HandlerTimeout > 0 && cfg.HandlerTimeout < cfg.Lease {
+		return cfg.HandlerTimeout
+	}
+
+	return cfg.Lease - cfg.Lease/10
+}
+
+// backoff returns the delay before the retry following attempt: BackoffBase
+// doubled for every attempt, capped at BackoffMax, with jitter over its
+// second half.
+func (cfg *Config) backoff(attempt int) time.Duration {
+	d := cfg.BackoffMax
+	if shift := attempt - 1; shift < 32 {
+		d = min(cfg.BackoffBase<<shift, cfg.BackoffMax)
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + mathrand.N(d/2+1)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func endSpan(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// WithConcurrency sets how many jobs each Work call runs at the same time
+// (default: 10).
+func WithConcurrency(n int) Option {
+	return func(cfg *Config) {
+		cfg.Concurrency = n
+	}
+}
+
+// WithMaxAttempts sets how many times a job runs before it is dead-lettered
+// (default: 5).
+func WithMaxAttempts(n int) Option {
+	return func(cfg *Config) {
+		cfg.MaxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry and its upper bound
+// (default: 1s and 1 hour).
+func WithBackoff(base, maxDelay time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.BackoffBase = base
+		cfg.BackoffMax = maxDelay
+	}
+}
+
+// WithPollInterval sets how long Work waits before looking again when no
+// job is ready (default: 1s).
+func WithPollInterval(interval time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.PollInterval = interval
+	}
+}
+
+// WithLease sets how long a running job is hidden from other workers
+// (default: 5 minutes). A job running longer would be run again by another
+// worker, so handlers time out before it ends.
+func WithLease(lease time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Lease = lease
+	}
+}
+
+// WithHandlerTimeout sets how long a handler may run (default: nine tenths
+// of Lease). A timeout not shorter than Lease is replaced by the default.
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.HandlerTimeout = timeout
+	}
+}
+
+// WithDrainTimeout sets how long Work waits for running jobs after its
+// context is done before cancelling them (default: 30s). Zero waits until
+// they return.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.DrainTimeout = timeout
+	}
+}
+
+// WithDeadRetention sets how long dead-lettered jobs are kept (default: 7
+// days). Older ones are deleted when another job of their queue is
+// dead-lettered. Zero keeps them forever.
+func WithDeadRetention(retention time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.DeadRetention = retention
+	}
+}
+
+// WithCodec sets the codec of typed payloads (default: cache.JSON).
+func WithCodec(codec cache.Codec) Option {
+	return func(cfg *Config) {
+		cfg.Codec = codec
+	}
+}
+
+// WithKeyPrefix sets the prefix of the Redis and Valkey keys (default:
+// "queue:").
+func WithKeyPrefix(prefix string) Option {
+	return func(cfg *Config) {
+		cfg.KeyPrefix = prefix
+	}
+}
+
+// WithTable sets the name of the SQL jobs table (default: "queue_jobs").
+func WithTable(table string) Option {
+	return func(cfg *Config) {
+		cfg.Table = table
+	}
+}
+
+// WithErrorHandler sets a function called when a handler returns an error or
+// the backend fails. job is nil for errors claiming jobs.
+func WithErrorHandler(fn func(job *Job, err error)) Option {
+	return func(cfg *Config) {
+		cfg.ErrorHandler = fn
+	}
+}
+
+// WithDelay runs the job no earlier than delay from now.
+func WithDelay(delay time.Duration) JobOption {
+	return func(jc *jobConfig) {
+		jc.runAt = time.Now().Add(delay)
+	}
+}
+
+// WithRunAt runs the job no earlier than t.
+func WithRunAt(t time.Time) JobOption {
+	return func(jc *jobConfig) {
+		jc.runAt = t
+	}
+}
+
+// WithJobMaxAttempts overrides MaxAttempts for the job.
+func WithJobMaxAttempts(n int) JobOption {
+	return func(jc *jobConfig) {
+		jc.maxAttempts = n
+	}
+}
@@ -0,0 +1,467 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stonear/go-dev-toolkit/database/sql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type order struct {
+	ID int `json:"id"`
+}
+
+// newTestQueue returns a queue over a SQLite file whose spans are recorded.
+func newTestQueue(t *testing.T, opts ...Option) (*Queue, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	db, err := sql.NewSQLite(sql.WithDatabase(filepath.Join(t.TempDir(), "queue.db")), sql.WithMaxOpen(1))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	opts = append([]Option{WithPollInterval(5 * time.Millisecond), WithBackoff(time.Millisecond, 5*time.Millisecond)}, opts...)
+	q, err := NewSQL(db, &sql.SQLiteDriver{}, opts...)
+	if err != nil {
+		t.Fatalf("NewSQL failed: %v", err)
+	}
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := tracetest.NewSpanRecorder()
+	q.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer(instrumentationName)
+
+	return q, recorder
+}
+
+// work runs q.Work in the background and returns a function stopping it.
+func work(q *Queue, queue string, handler Handler) (stop func() error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Work(ctx, queue, handler) }()
+
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWork(t *testing.T) {
+	q, recorder := newTestQueue(t, WithConcurrency(3))
+	ctx := context.Background()
+
+	for i := range 10 {
+		if _, err := Enqueue(ctx, q, "orders", order{ID: i}); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	var running, peak atomic.Int32
+	stop := work(q, "orders", Handle(func(ctx context.Context, o order) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		seen[o.ID] = true
+		mu.Unlock()
+		return nil
+	}))
+
+	waitFor(t, "every job", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen) == 10
+	})
+	if err := stop(); err != nil {
+		t.Errorf("expected nil after stop, got %v", err)
+	}
+
+	if p := peak.Load(); p > 3 {
+		t.Errorf("expected at most 3 jobs at a time, got %d", p)
+	}
+
+	var count int
+	_ = q.backend.(*sqlBackend).db.QueryRow("SELECT COUNT(*) FROM queue_jobs").Scan(&count)
+	if count != 0 {
+		t.Errorf("expected completed jobs to be deleted, got %d rows", count)
+	}
+
+	// Each job runs in a consumer span linked to the span that enqueued it.
+	producers := make(map[trace.SpanID]bool)
+	linked := 0
+	spans := recorder.Ended()
+	for _, span := range spans {
+		if span.SpanKind() == trace.SpanKindProducer {
+			producers[span.SpanContext().SpanID()] = true
+		}
+	}
+	for _, span := range spans {
+		if span.SpanKind() == trace.SpanKindConsumer && len(span.Links()) == 1 && producers[span.Links()[0].SpanContext.SpanID()] {
+			linked++
+		}
+	}
+	if linked != 10 {
+		t.Errorf("expected 10 linked consumer spans, got %d", linked)
+	}
+}
+
+func TestWork_RetryAndDeadLetter(t *testing.T) {
+	var reported atomic.Int32
+	q, _ := newTestQueue(t, WithMaxAttempts(3), WithErrorHandler(func(job *Job, err error) {
+		reported.Add(1)
+	}))
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, "emails", []byte("hello"))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	var attempts []int
+	var mu sync.Mutex
+	stop := work(q, "emails", func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		attempts = append(attempts, job.Attempt)
+		mu.Unlock()
+		return errors.New("smtp unavailable")
+	})
+
+	waitFor(t, "the job to be dead-lettered", func() bool {
+		jobs, _ := q.Dead(ctx, "emails", 10)
+		return len(jobs) == 1
+	})
+	_ = stop()
+
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Errorf("expected attempts 1 to 3, got %v", attempts)
+	}
+	if n := reported.Load(); n != 3 {
+		t.Errorf("expected 3 reported errors, got %d", n)
+	}
+
+	jobs, _ := q.Dead(ctx, "emails", 10)
+	if job := jobs[0]; job.ID != id || string(job.Payload) != "hello" || job.LastError != "smtp unavailable" {
+		t.Errorf("unexpected dead job: %+v", job)
+	}
+
+	// A requeued job runs again from its first attempt.
+	if err := q.Requeue(ctx, "emails", id); err != nil {
+		t.Fatalf("Requeue failed: %v", err)
+	}
+	if err := q.Requeue(ctx, "emails", id); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+
+	done := make(chan int, 1)
+	stop = work(q, "emails", func(ctx context.Context, job *Job) error {
+		done <- job.Attempt
+		return nil
+	})
+	if attempt := <-done; attempt != 1 {
+		t.Errorf("expected attempt 1 after Requeue, got %d", attempt)
+	}
+	_ = stop()
+}
+
+func TestWork_Permanent(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+
+	_, _ = q.Enqueue(ctx, "orders", []byte("not json"))
+
+	var calls atomic.Int32
+	stop := work(q, "orders", Handle(func(ctx context.Context, o order) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	waitFor(t, "the job to be dead-lettered", func() bool {
+		jobs, _ := q.Dead(ctx, "orders", 10)
+		return len(jobs) == 1 && jobs[0].Attempt == 1 && strings.Contains(jobs[0].LastError, "decode payload")
+	})
+	_ = stop()
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected the handler not to be called, got %d calls", n)
+	}
+}
+
+func TestWork_Delay(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+
+	_, _ = q.Enqueue(ctx, "reports", []byte("later"), WithDelay(time.Hour))
+	_, _ = q.Enqueue(ctx, "reports", []byte("soon"), WithRunAt(time.Now().Add(50*time.Millisecond)))
+
+	ran := make(chan string, 2)
+	stop := work(q, "reports", func(ctx context.Context, job *Job) error {
+		ran <- string(job.Payload)
+		return nil
+	})
+	defer stop()
+
+	start := time.Now()
+	if payload := <-ran; payload != "soon" || time.Since(start) < 40*time.Millisecond {
+		t.Errorf("expected soon after its run time, got %s after %s", payload, time.Since(start))
+	}
+
+	select {
+	case payload := <-ran:
+		t.Errorf("expected the delayed job to wait, got %s", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWork_Drain(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		wantDone bool
+	}{
+		{"Finishes", 0, true},
+		{"Cancelled", 20 * time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := newTestQueue(t, WithDrainTimeout(tt.timeout))
+			ctx := context.Background()
+			_, _ = q.Enqueue(ctx, "slow", []byte("x"))
+
+			started := make(chan struct{})
+			var finished atomic.Bool
+			stop := work(q, "slow", func(ctx context.Context, job *Job) error {
+				close(started)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(100 * time.Millisecond):
+					finished.Store(true)
+					return nil
+				}
+			})
+
+			<-started
+			if err := stop(); err != nil {
+				t.Errorf("expected nil after stop, got %v", err)
+			}
+			if finished.Load() != tt.wantDone {
+				t.Errorf("expected finished %v when Work returned", tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestWork_Panic(t *testing.T) {
+	errs := make(chan error, 1)
+	q, _ := newTestQueue(t, WithMaxAttempts(1), WithErrorHandler(func(job *Job, err error) {
+		errs <- err
+	}))
+	_, _ = q.Enqueue(context.Background(), "orders", []byte("x"))
+
+	stop := work(q, "orders", func(ctx context.Context, job *Job) error {
+		panic("boom")
+	})
+	defer stop()
+
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Errorf("expected the panic to be reported, got %v", err)
+	}
+}
+
+func TestWork_ExpiredLease(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	b := q.backend
+
+	id, _ := q.Enqueue(ctx, "orders", []byte("x"))
+
+	// The first worker stalls past its lease and the job is claimed again.
+	stale, err := b.claim(ctx, "orders", 1, time.Millisecond)
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("expected 1 claimed job, got %d (%v)", len(stale), err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	fresh, err := b.claim(ctx, "orders", 1, time.Hour)
+	if err != nil || len(fresh) != 1 || fresh[0].Attempt != 2 {
+		t.Fatalf("expected the job reclaimed on attempt 2, got %v (%v)", fresh, err)
+	}
+
+	// The stale worker can no longer complete, retry or bury it.
+	stale[0].LastError = "stale"
+	if err := b.complete(ctx, stale[0]); err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if err := b.retry(ctx, stale[0], time.Now()); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if err := b.bury(ctx, stale[0], time.Time{}); err != nil {
+		t.Fatalf("bury failed: %v", err)
+	}
+	db := b.(*sqlBackend).db
+	var status, lastError string
+	if err := db.QueryRow("SELECT status, last_error FROM queue_jobs WHERE id = ?", id).Scan(&status, &lastError); err != nil {
+		t.Fatalf("expected the job to be kept: %v", err)
+	}
+	if status != "ready" || lastError != "" {
+		t.Errorf("expected the job untouched, got status %q and error %q", status, lastError)
+	}
+	if jobs, _ := b.claim(ctx, "orders", 1, time.Hour); len(jobs) != 0 {
+		t.Errorf("expected the job to stay leased to the new worker, got %v", jobs)
+	}
+
+	// The current worker still can.
+	if err := b.complete(ctx, fresh[0]); err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	var count int
+	_ = db.QueryRow("SELECT COUNT(*) FROM queue_jobs WHERE id = ?", id).Scan(&count)
+	if count != 0 {
+		t.Errorf("expected the job to be deleted, got %d rows", count)
+	}
+}
+
+func TestDead_Retention(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	b := q.backend
+	db := b.(*sqlBackend).db
+
+	// Bury a job failed an hour ago, then one failed now with a retention of
+	// a minute.
+	for range 2 {
+		_, _ = q.Enqueue(ctx, "orders", []byte("x"))
+	}
+	jobs, err := b.claim(ctx, "orders", 2, time.Hour)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("expected 2 claimed jobs, got %d (%v)", len(jobs), err)
+	}
+	if err := b.bury(ctx, jobs[0], time.Time{}); err != nil {
+		t.Fatalf("bury failed: %v", err)
+	}
+	_, _ = db.Exec("UPDATE queue_jobs SET run_at = ? WHERE id = ?", time.Now().Add(-time.Hour).UnixMilli(), jobs[0].ID)
+	if err := b.bury(ctx, jobs[1], time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("bury failed: %v", err)
+	}
+
+	dead, err := q.Dead(ctx, "orders", 0)
+	if err != nil {
+		t.Fatalf("Dead failed: %v", err)
+	}
+	if len(dead) != 1 || dead[0].ID != jobs[1].ID {
+		t.Errorf("expected only the recent dead job to be kept, got %v", dead)
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		timeout, want time.Duration
+	}{
+		{0, 9 * time.Second},
+		{5 * time.Second, 5 * time.Second},
+		{10 * time.Second, 9 * time.Second},
+		{time.Minute, 9 * time.Second},
+	} {
+		cfg := newConfig([]Option{WithLease(10 * time.Second), WithHandlerTimeout(tt.timeout)})
+		if got := cfg.handlerTimeout(); got != tt.want {
+			t.Errorf("handlerTimeout(%s) = %s, want %s", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := newConfig([]Option{WithBackoff(time.Second, 10*time.Second)})
+
+	for _, tt := range []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{5, 5 * time.Second, 10 * time.Second},
+		{100, 5 * time.Second, 10 * time.Second},
+	} {
+		if d := cfg.backoff(tt.attempt); d < tt.min || d > tt.max {
+			t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.min, tt.max)
+		}
+	}
+}
+
+func TestSelectJobs(t *testing.T) {
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{"pgx", "SELECT " + columns + " FROM jobs WHERE queue = $1 AND status = $2 AND run_at <= $3 ORDER BY run_at LIMIT $4 FOR UPDATE SKIP LOCKED"},
+		{"mysql", "SELECT " + columns + " FROM jobs WHERE queue = ? AND status = ? AND run_at <= ? ORDER BY run_at LIMIT ? FOR UPDATE SKIP LOCKED"},
+		{"sqlserver", "SELECT TOP (@p1) " + columns + " FROM jobs WITH (UPDLOCK, READPAST, ROWLOCK) WHERE queue = @p2 AND status = @p3 AND run_at <= @p4 ORDER BY run_at"},
+	}
+
+	for _, tt := range tests {
+		b := &sqlBackend{dialect: dialects[tt.driver], table: "jobs"}
+		query, args := b.selectJobs("q", "ready", 1, 5, true)
+		if query != tt.want {
+			t.Errorf("%s: got %q", tt.driver, query)
+		}
+		if len(args) != 4 {
+			t.Errorf("%s: expected 4 arguments, got %v", tt.driver, args)
+		}
+	}
+
+	if _, err := NewSQL(nil, &mockDriver{}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for an unknown driver, got %v", err)
+	}
+}
+
+type mockDriver struct{}
+
+func (*mockDriver) DSN(*sql.DB) string { return "" }
+func (*mockDriver) Name() string       { return "mock" }
+
+func TestParseJobs(t *testing.T) {
+	jobs, err := parseJobs("orders", []string{
+		"a", "2", "payload", `{"traceparent":"x"}`, "5", "failed", "1700000000000",
+		"b", "1", "", "", "3", "", "1700000000000",
+	})
+	if err != nil {
+		t.Fatalf("parseJobs failed: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	if j := jobs[0]; j.ID != "a" || j.Attempt != 2 || string(j.Payload) != "payload" || j.Headers["traceparent"] != "x" || j.MaxAttempts != 5 || j.LastError != "failed" {
+		t.Errorf("unexpected job: %+v", j)
+	}
+	if j := jobs[1]; j.Queue != "orders" || j.CreatedAt.UnixMilli() != 1700000000000 {
+		t.Errorf("unexpected job: %+v", j)
+	}
+}
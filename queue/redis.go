@@ -0,0 +1,25 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/stonear/go-dev-toolkit/cache"
+)
+
+// NewRedis returns a Queue that stores jobs with the client of c.
+func NewRedis(c *cache.RedisCache, opts ...Option) *Queue {
+	cfg := newConfig(opts)
+	client := c.Client()
+
+	return newQueue(&keyBackend{
+		prefix: cfg.KeyPrefix,
+		eval: func(ctx context.Context, s *luaScript, keys, args []string) ([]string, error) {
+			values := make([]any, len(args))
+			for i, arg := range args {
+				values[i] = arg
+			}
+
+			return s.redis.Run(ctx, client, keys, values...).StringSlice()
+		},
+	}, "redis", cfg)
+}
@@ -0,0 +1,258 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/valkey-io/valkey-go"
+)
+
+// luaScript is a script runnable on both Redis and Valkey clients.
+type luaScript struct {
+	redis  *redis.Script
+	valkey *valkey.Lua
+}
+
+func newLuaScript(src string) *luaScript {
+	return &luaScript{redis: redis.NewScript(src), valkey: valkey.NewLuaScript(src)}
+}
+
+// Jobs are hashes, and a queue is a sorted set of job IDs scored by the time
+// they become ready. Claiming a job pushes its score to the end of its lease,
+// so it runs again if its worker crashes. Dead-lettered jobs move to a second
+// sorted set scored by the time they failed, and bury deletes up to deadTrim
+// of those older than the retention. Every key of a queue shares a hash tag,
+// so the scripts work on clusters.
+//
+// complete, retry and bury only change a job while its attempt is still the
+// one its worker claimed, so a worker whose lease expired cannot undo a newer
+// claim.
+//
+// Scripts returning jobs return flat arrays of jobFields values per job.
+
+const (
+	jobFields = 7   // id, attempt, payload, headers, max_attempts, last_error, created_at
+	deadTrim  = 100 // expired dead-lettered jobs deleted per bury
+)
+
+var (
+	enqueueScript = newLuaScript(`
+redis.call('HSET', KEYS[2], 'payload', ARGV[3], 'headers', ARGV[4], 'attempt', 0,
+	'max_attempts', ARGV[5], 'last_error', '', 'created_at', ARGV[6])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+return {}
+`)
+
+	claimScript = newLuaScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+local out = {}
+for _, id in ipairs(ids) do
+	local key = ARGV[4] .. id
+	if redis.call('EXISTS', key) == 1 then
+		redis.call('ZADD', KEYS[1], ARGV[2], id)
+		local attempt = redis.call('HINCRBY', key, 'attempt', 1)
+		local f = redis.call('HMGET', key, 'payload', 'headers', 'max_attempts', 'last_error', 'created_at')
+		table.insert(out, id)
+		table.insert(out, tostring(attempt))
+		for i = 1, 5 do
+			table.insert(out, f[i] or '')
+		end
+	else
+		redis.call('ZREM', KEYS[1], id)
+	end
+end
+return out
+`)
+
+	completeScript = newLuaScript(`
+if redis.call('HGET', KEYS[2], 'attempt') ~= ARGV[2] then
+	return {}
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('DEL', KEYS[2])
+return {}
+`)
+
+	retryScript = newLuaScript(`
+if redis.call('HGET', KEYS[2], 'attempt') == ARGV[4] then
+	redis.call('HSET', KEYS[2], 'last_error', ARGV[3])
+	redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+end
+return {}
+`)
+
+	buryScript = newLuaScript(`
+if redis.call('HGET', KEYS[3], 'attempt') == ARGV[4] then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+	redis.call('HSET', KEYS[3], 'last_error', ARGV[3])
+	redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+end
+if ARGV[5] ~= '' then
+	local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', '(' .. ARGV[5], 'LIMIT', 0, ARGV[6])
+	for _, id in ipairs(expired) do
+		redis.call('ZREM', KEYS[2], id)
+		redis.call('DEL', ARGV[7] .. id)
+	end
+end
+return {}
+`)
+
+	deadScript = newLuaScript(`
+local ids = redis.call('ZRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)
+local out = {}
+for _, id in ipairs(ids) do
+	local f = redis.call('HMGET', ARGV[2] .. id, 'attempt', 'payload', 'headers', 'max_attempts', 'last_error', 'created_at')
+	table.insert(out, id)
+	for i = 1, 6 do
+		table.insert(out, f[i] or '')
+	end
+end
+return out
+`)
+
+	requeueScript = newLuaScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return {}
+end
+redis.call('HSET', KEYS[3], 'attempt', 0)
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+return {ARGV[1]}
+`)
+)
+
+// keyBackend stores jobs in Redis or Valkey.
+type keyBackend struct {
+	prefix string
+	eval   func(ctx context.Context, s *luaScript, keys, args []string) ([]string, error)
+}
+
+func (b *keyBackend) key(queue, suffix string) string {
+	return b.prefix + "{" + queue + "}:" + suffix
+}
+
+func (b *keyBackend) enqueue(ctx context.Context, job *Job, runAt time.Time) error {
+	headers, err := json.Marshal(job.Headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.eval(ctx, enqueueScript,
+		[]string{b.key(job.Queue, "scheduled"), b.key(job.Queue, "job:"+job.ID)},
+		[]string{job.ID, millis(runAt), string(job.Payload), string(headers), strconv.Itoa(job.MaxAttempts), millis(job.CreatedAt)},
+	)
+
+	return err
+}
+
+func (b *keyBackend) claim(ctx context.Context, queue string, n int, lease time.Duration) ([]*Job, error) {
+	now := time.Now()
+	values, err := b.eval(ctx, claimScript,
+		[]string{b.key(queue, "scheduled")},
+		[]string{millis(now), millis(now.Add(lease)), strconv.Itoa(n), b.key(queue, "job:")},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJobs(queue, values)
+}
+
+func (b *keyBackend) complete(ctx context.Context, job *Job) error {
+	_, err := b.eval(ctx, completeScript,
+		[]string{b.key(job.Queue, "scheduled"), b.key(job.Queue, "job:"+job.ID)},
+		[]string{job.ID, strconv.Itoa(job.Attempt)},
+	)
+
+	return err
+}
+
+func (b *keyBackend) retry(ctx context.Context, job *Job, runAt time.Time) error {
+	_, err := b.eval(ctx, retryScript,
+		[]string{b.key(job.Queue, "scheduled"), b.key(job.Queue, "job:"+job.ID)},
+		[]string{job.ID, millis(runAt), job.LastError, strconv.Itoa(job.Attempt)},
+	)
+
+	return err
+}
+
+func (b *keyBackend) bury(ctx context.Context, job *Job, expired time.Time) error {
+	var until string
+	if !expired.IsZero() {
+		until = millis(expired)
+	}
+
+	_, err := b.eval(ctx, buryScript,
+		[]string{b.key(job.Queue, "scheduled"), b.key(job.Queue, "dead"), b.key(job.Queue, "job:"+job.ID)},
+		[]string{job.ID, millis(time.Now()), job.LastError, strconv.Itoa(job.Attempt), until, strconv.Itoa(deadTrim), b.key(job.Queue, "job:")},
+	)
+
+	return err
+}
+
+func (b *keyBackend) dead(ctx context.Context, queue string, limit int) ([]*Job, error) {
+	values, err := b.eval(ctx, deadScript,
+		[]string{b.key(queue, "dead")},
+		[]string{strconv.Itoa(limit), b.key(queue, "job:")},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJobs(queue, values)
+}
+
+func (b *keyBackend) requeue(ctx context.Context, queue, id string) error {
+	values, err := b.eval(ctx, requeueScript,
+		[]string{b.key(queue, "dead"), b.key(queue, "scheduled"), b.key(queue, "job:"+id)},
+		[]string{id, millis(time.Now())},
+	)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// parseJobs reads the jobs returned by a script.
+func parseJobs(queue string, values []string) ([]*Job, error) {
+	jobs := make([]*Job, 0, len(values)/jobFields)
+	for f := range slices.Chunk(values, jobFields) {
+		if len(f) < jobFields {
+			break
+		}
+
+		attempt, _ := strconv.Atoi(f[1])
+		maxAttempts, _ := strconv.Atoi(f[4])
+		createdAt, _ := strconv.ParseInt(f[6], 10, 64)
+
+		job := &Job{
+			ID:          f[0],
+			Queue:       queue,
+			Payload:     []byte(f[2]),
+			Attempt:     attempt,
+			MaxAttempts: maxAttempts,
+			LastError:   f[5],
+			CreatedAt:   time.UnixMilli(createdAt),
+		}
+		if f[3] != "" {
+			if err := json.Unmarshal([]byte(f[3]), &job.Headers); err != nil {
+				return nil, err
+			}
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
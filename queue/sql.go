@@ -0,0 +1,346 @@
+package queue
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stonear/go-dev-toolkit/database/sql"
+)
+
+// dialect holds the SQL that differs between databases.
+type dialect struct {
+	system      string // messaging.system of the spans
+	placeholder func(n int) string
+	schema      []string // statements creating the table, formatted with its name
+	top         bool     // SQL Server: TOP (n) and table hints instead of LIMIT and FOR UPDATE
+	skipLocked  bool     // claims lock rows with SKIP LOCKED or READPAST instead of polling
+}
+
+const columns = "id, payload, headers, attempt, max_attempts, last_error, created_at, run_at"
+
+var dialects = map[string]dialect{
+	"pgx": {
+		system:      "postgresql",
+		placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS %[1]s (
+	id VARCHAR(64) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	payload BYTEA,
+	headers TEXT NOT NULL,
+	attempt INTEGER NOT NULL,
+	max_attempts INTEGER NOT NULL,
+	last_error TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	run_at BIGINT NOT NULL
+)`,
+			`CREATE INDEX IF NOT EXISTS %[1]s_ready ON %[1]s (queue, status, run_at)`,
+		},
+		skipLocked: true,
+	},
+	"mysql": {
+		system:      "mysql",
+		placeholder: func(int) string { return "?" },
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS %[1]s (
+	id VARCHAR(64) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	payload LONGBLOB,
+	headers TEXT NOT NULL,
+	attempt INT NOT NULL,
+	max_attempts INT NOT NULL,
+	last_error TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	run_at BIGINT NOT NULL,
+	INDEX %[1]s_ready (queue, status, run_at)
+)`,
+		},
+		skipLocked: true,
+	},
+	"sqlserver": {
+		system:      "mssql",
+		placeholder: func(n int) string { return "@p" + strconv.Itoa(n) },
+		schema: []string{
+			`IF OBJECT_ID(N'%[1]s', N'U') IS NULL CREATE TABLE %[1]s (
+	id NVARCHAR(64) PRIMARY KEY,
+	queue NVARCHAR(255) NOT NULL,
+	status NVARCHAR(16) NOT NULL,
+	payload VARBINARY(MAX),
+	headers NVARCHAR(MAX) NOT NULL,
+	attempt INT NOT NULL,
+	max_attempts INT NOT NULL,
+	last_error NVARCHAR(MAX) NOT NULL,
+	created_at BIGINT NOT NULL,
+	run_at BIGINT NOT NULL,
+	INDEX %[1]s_ready (queue, status, run_at)
+)`,
+		},
+		top:        true,
+		skipLocked: true,
+	},
+	"sqlite": {
+		system:      "sqlite",
+		placeholder: func(int) string { return "?" },
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS %[1]s (
+	id TEXT PRIMARY KEY,
+	queue TEXT NOT NULL,
+	status TEXT NOT NULL,
+	payload BLOB,
+	headers TEXT NOT NULL,
+	attempt INTEGER NOT NULL,
+	max_attempts INTEGER NOT NULL,
+	last_error TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	run_at INTEGER NOT NULL
+)`,
+			`CREATE INDEX IF NOT EXISTS %[1]s_ready ON %[1]s (queue, status, run_at)`,
+		},
+	},
+}
+
+// sqlBackend stores jobs in a table, one row per job. Ready jobs have the
+// "ready" status and run_at holds when they become ready or, once claimed,
+// when their lease ends; dead-lettered jobs have the "dead" status and
+// run_at holds when they failed.
+type sqlBackend struct {
+	db      *stdsql.DB
+	dialect dialect
+	table   string
+}
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (stdsql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*stdsql.Rows, error)
+}
+
+// NewSQL returns a Queue that stores jobs in a table of db, a connection
+// opened by sql.New with driver, creating the table if it does not exist.
+// On PostgreSQL and MySQL 8+ workers claim jobs with SELECT ... FOR UPDATE
+// SKIP LOCKED, and on SQL Server with the READPAST hint; on SQLite they poll
+// and claim jobs with conditional updates.
+func NewSQL(db *stdsql.DB, driver sql.Driver, opts ...Option) (*Queue, error) {
+	d, ok := dialects[driver.Name()]
+	if !ok {
+		return nil, fmt.Errorf("queue: driver %s: %w", driver.Name(), errors.ErrUnsupported)
+	}
+
+	cfg := newConfig(opts)
+	for _, stmt := range d.schema {
+		if _, err := db.Exec(fmt.Sprintf(stmt, cfg.Table)); err != nil {
+			return nil, err
+		}
+	}
+
+	return newQueue(&sqlBackend{db: db, dialect: d, table: cfg.Table}, d.system, cfg), nil
+}
+
+// bind replaces the ? placeholders of query with those of the dialect.
+func (b *sqlBackend) bind(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString(b.dialect.placeholder(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// selectJobs returns the query and arguments selecting up to limit jobs of
+// queue with status whose run_at is before until, oldest first.
+func (b *sqlBackend) selectJobs(queue, status string, until int64, limit int, lock bool) (string, []any) {
+	where := "queue = ? AND status = ? AND run_at <= ?"
+	args := []any{queue, status, until}
+
+	if b.dialect.top {
+		hints := ""
+		if lock {
+			hints = " WITH (UPDLOCK, READPAST, ROWLOCK)"
+		}
+		query := fmt.Sprintf("SELECT TOP (?) %s FROM %s%s WHERE %s ORDER BY run_at", columns, b.table, hints, where)
+		return b.bind(query), append([]any{limit}, args...)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY run_at LIMIT ?", columns, b.table, where)
+	if lock {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+
+	return b.bind(query), append(args, limit)
+}
+
+func (b *sqlBackend) enqueue(ctx context.Context, job *Job, runAt time.Time) error {
+	headers, err := json.Marshal(job.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, queue, status, payload, headers, attempt, max_attempts, last_error, created_at, run_at)
+VALUES (?, ?, 'ready', ?, ?, 0, ?, '', ?, ?)`, b.table)
+	_, err = b.db.ExecContext(ctx, b.bind(query),
+		job.ID, job.Queue, job.Payload, string(headers), job.MaxAttempts, job.CreatedAt.UnixMilli(), runAt.UnixMilli())
+
+	return err
+}
+
+func (b *sqlBackend) claim(ctx context.Context, queue string, n int, lease time.Duration) ([]*Job, error) {
+	if !b.dialect.skipLocked {
+		return b.poll(ctx, queue, n, lease)
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	query, args := b.selectJobs(queue, "ready", now.UnixMilli(), n, true)
+	jobs, _, err := b.query(ctx, tx, queue, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	update := b.bind(fmt.Sprintf("UPDATE %s SET attempt = attempt + 1, run_at = ? WHERE id = ?", b.table))
+	for _, job := range jobs {
+		if _, err := tx.ExecContext(ctx, update, now.Add(lease).UnixMilli(), job.ID); err != nil {
+			return nil, err
+		}
+		job.Attempt++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// poll claims jobs without row locks: each selected job is claimed by an
+// update that only succeeds if no other worker claimed it in between.
+func (b *sqlBackend) poll(ctx context.Context, queue string, n int, lease time.Duration) ([]*Job, error) {
+	now := time.Now()
+	query, args := b.selectJobs(queue, "ready", now.UnixMilli(), n, false)
+	candidates, runAts, err := b.query(ctx, b.db, queue, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	update := b.bind(fmt.Sprintf("UPDATE %s SET attempt = attempt + 1, run_at = ? WHERE id = ? AND status = 'ready' AND run_at = ?", b.table))
+	jobs := candidates[:0]
+	for i, job := range candidates {
+		res, err := b.db.ExecContext(ctx, update, now.Add(lease).UnixMilli(), job.ID, runAts[i])
+		if err != nil {
+			return jobs, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n != 1 {
+			continue
+		}
+
+		job.Attempt++
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// query runs a query selecting columns and returns the jobs with their run_at.
+func (b *sqlBackend) query(ctx context.Context, q querier, queue, query string, args ...any) ([]*Job, []int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	var runAts []int64
+	for rows.Next() {
+		job := &Job{Queue: queue}
+		var headers string
+		var createdAt, runAt int64
+		if err := rows.Scan(&job.ID, &job.Payload, &headers, &job.Attempt, &job.MaxAttempts, &job.LastError, &createdAt, &runAt); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal([]byte(headers), &job.Headers); err != nil {
+			return nil, nil, err
+		}
+		job.CreatedAt = time.UnixMilli(createdAt)
+
+		jobs = append(jobs, job)
+		runAts = append(runAts, runAt)
+	}
+
+	return jobs, runAts, rows.Err()
+}
+
+// complete, retry and bury only change the job while it is still claimed by
+// this attempt, so a worker whose lease expired cannot undo a newer claim.
+func (b *sqlBackend) complete(ctx context.Context, job *Job) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = ? AND attempt = ?", b.table)
+	_, err := b.db.ExecContext(ctx, b.bind(query), job.ID, job.Attempt)
+
+	return err
+}
+
+func (b *sqlBackend) retry(ctx context.Context, job *Job, runAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET last_error = ?, run_at = ? WHERE id = ? AND status = 'ready' AND attempt = ?", b.table)
+	_, err := b.db.ExecContext(ctx, b.bind(query), job.LastError, runAt.UnixMilli(), job.ID, job.Attempt)
+
+	return err
+}
+
+// bury also deletes the dead-lettered jobs of the queue that failed before
+// expired, unless it is zero.
+func (b *sqlBackend) bury(ctx context.Context, job *Job, expired time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET status = 'dead', last_error = ?, run_at = ? WHERE id = ? AND status = 'ready' AND attempt = ?", b.table)
+	if _, err := b.db.ExecContext(ctx, b.bind(query), job.LastError, time.Now().UnixMilli(), job.ID, job.Attempt); err != nil {
+		return err
+	}
+	if expired.IsZero() {
+		return nil
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE queue = ? AND status = 'dead' AND run_at < ?", b.table)
+	_, err := b.db.ExecContext(ctx, b.bind(query), job.Queue, expired.UnixMilli())
+
+	return err
+}
+
+func (b *sqlBackend) dead(ctx context.Context, queue string, limit int) ([]*Job, error) {
+	query, args := b.selectJobs(queue, "dead", time.Now().UnixMilli(), limit, false)
+	jobs, _, err := b.query(ctx, b.db, queue, query, args...)
+
+	return jobs, err
+}
+
+func (b *sqlBackend) requeue(ctx context.Context, queue, id string) error {
+	query := fmt.Sprintf("UPDATE %s SET status = 'ready', attempt = 0, run_at = ? WHERE id = ? AND queue = ? AND status = 'dead'", b.table)
+	res, err := b.db.ExecContext(ctx, b.bind(query), time.Now().UnixMilli(), id, queue)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
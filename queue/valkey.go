@@ -0,0 +1,20 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/stonear/go-dev-toolkit/cache"
+)
+
+// NewValkey returns a Queue that stores jobs with the client of c.
+func NewValkey(c *cache.ValkeyCache, opts ...Option) *Queue {
+	cfg := newConfig(opts)
+	client := c.Client()
+
+	return newQueue(&keyBackend{
+		prefix: cfg.KeyPrefix,
+		eval: func(ctx context.Context, s *luaScript, keys, args []string) ([]string, error) {
+			return s.valkey.Exec(ctx, client, keys, args).AsStrSlice()
+		},
+	}, "valkey", cfg)
+}